@@ -0,0 +1,389 @@
+package goja
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+var (
+	errorType         = reflect.TypeFor[error]()
+	jsValueType       = reflect.TypeFor[goja.Value]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Bind exposes the methods of v listed in methods, by their Go names, as the
+// functions of a new JS object named in lower camel case: GetUser becomes
+// getUser and HTTPStatus httpStatus. Methods not listed are not reachable
+// from JS. When T is an interface type only its methods can be listed.
+//
+// Arguments are converted to the parameter types of the method, a missing or
+// mismatching argument throws a TypeError naming the function and the
+// argument. A method may return a value, an error or both: a non nil error
+// is thrown as a GoError, and the run returns an error matching it with
+// errors.Is and errors.As.
+//
+// Results are copied into plain JS data: structs become objects of their
+// exported fields, named like the fields of the arguments, slices and arrays
+// become arrays, maps objects, and values implementing
+// encoding.TextMarshaler strings. The methods of the results are not
+// reachable, they have to be bound on their own.
+func Bind[T any](runtime *goja.Runtime, v T, methods ...string) (*goja.Object, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, errors.New("goja: cannot bind a nil value")
+	}
+	// the method set of an interface type is the one exposed
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Interface {
+		typ = rv.Type()
+	}
+
+	obj := runtime.NewObject()
+	for _, name := range methods {
+		if _, ok := typ.MethodByName(name); !ok {
+			return nil, errors.Errorf("goja: %s has no exported method %s", typ, name)
+		}
+		method := rv.MethodByName(name)
+		jsName := lowerCamel(name)
+		fn, err := bindFunc(runtime, jsName, method)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to bind %s.%s", typ, name)
+		}
+		if err := obj.DefineDataProperty(jsName, runtime.ToValue(fn), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE); err != nil {
+			return nil, errors.Wrapf(err, "failed to define %s", jsName)
+		}
+	}
+	return obj, nil
+}
+
+func bindFunc(runtime *goja.Runtime, name string, fn reflect.Value) (func(goja.FunctionCall) goja.Value, error) {
+	t := fn.Type()
+	returnsErr := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	switch {
+	case t.NumOut() > 2, t.NumOut() == 2 && !returnsErr:
+		return nil, errors.New("results must be (), (T), (error) or (T, error)")
+	}
+
+	params := t.NumIn()
+	if t.IsVariadic() {
+		params--
+	}
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) > params && !t.IsVariadic() {
+			panic(runtime.NewTypeError("%s: expects %s, got %d", name, plural(params, "argument"), len(call.Arguments)))
+		}
+		args := make([]reflect.Value, 0, max(params, len(call.Arguments)))
+		for i := 0; i < params; i++ {
+			args = append(args, convertArg(runtime, name, i, call.Argument(i), t.In(i)))
+		}
+		if t.IsVariadic() {
+			elem := t.In(params).Elem()
+			for i := params; i < len(call.Arguments); i++ {
+				args = append(args, convertArg(runtime, name, i, call.Arguments[i], elem))
+			}
+		}
+
+		out := fn.Call(args)
+		if returnsErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				panic(runtime.NewGoError(err))
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return goja.Undefined()
+		}
+		return toJS(runtime, name, out[0], map[visitKey]bool{})
+	}, nil
+}
+
+// toJS copies the result v of the function name into plain JS data, or
+// throws a TypeError for functions, channels and cycles. visiting holds the
+// pointers, maps and slices being copied.
+func toJS(runtime *goja.Runtime, name string, v reflect.Value, visiting map[visitKey]bool) goja.Value {
+	fail := func(reason string) {
+		panic(runtime.NewTypeError("%s: cannot return %s: %s", name, v.Type(), reason))
+	}
+	// enter marks v as being copied until the function it returns is called
+	enter := func() func() {
+		key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if visiting[key] {
+			fail("cyclic value")
+		}
+		visiting[key] = true
+		return func() { delete(visiting, key) }
+	}
+
+	if !v.IsValid() {
+		return goja.Null()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return goja.Null()
+		}
+	}
+	switch {
+	case v.Type().Implements(jsValueType):
+		return v.Interface().(goja.Value)
+	case v.Type().Implements(textMarshalerType):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			panic(runtime.NewGoError(err))
+		}
+		return runtime.ToValue(string(text))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return runtime.ToValue(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return runtime.ToValue(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return runtime.ToValue(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return runtime.ToValue(v.Float())
+	case reflect.String:
+		return runtime.ToValue(v.String())
+	case reflect.Interface:
+		return toJS(runtime, name, v.Elem(), visiting)
+	case reflect.Pointer:
+		defer enter()()
+		return toJS(runtime, name, v.Elem(), visiting)
+	case reflect.Map:
+		defer enter()()
+		obj := runtime.NewObject()
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				fail(err.Error())
+			}
+			_ = obj.Set(key, toJS(runtime, name, iter.Value(), visiting))
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			defer enter()()
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = toJS(runtime, name, v.Index(i), visiting)
+		}
+		return runtime.NewArray(items...)
+	case reflect.Struct:
+		obj := runtime.NewObject()
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				continue // promoted through a nil embedded pointer
+			}
+			_ = obj.Set(f.Name, toJS(runtime, name, fv, visiting))
+		}
+		return obj
+	}
+	fail("unsupported type")
+	return nil
+}
+
+// visitKey identifies a pointer, map or slice being copied by toJS.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// mapKey returns the property name of the map key k.
+func mapKey(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", errors.Errorf("unsupported map key %s", k.Type())
+}
+
+// convertArg converts the i-th argument v to typ, or throws a TypeError.
+func convertArg(runtime *goja.Runtime, name string, i int, v goja.Value, typ reflect.Type) reflect.Value {
+	fail := func() {
+		panic(runtime.NewTypeError("%s: argument %d must be %s, got %s", name, i+1, describeType(typ), describeValue(v)))
+	}
+	nullish := goja.IsUndefined(v) || goja.IsNull(v)
+	// Go values handed to JS come back as they are
+	if !nullish && v.ExportType() == typ {
+		return reflect.ValueOf(v.Export())
+	}
+
+	rv := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		s, ok := v.Export().(string)
+		if !ok {
+			fail()
+		}
+		rv.SetString(s)
+	case reflect.Bool:
+		b, ok := v.Export().(bool)
+		if !ok {
+			fail()
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := number(v)
+		if !ok || f != math.Trunc(f) {
+			fail()
+		}
+		// the bounds are powers of two, exact as floats, and checked before
+		// converting since converting an out of range float is undefined
+		limit := math.Ldexp(1, typ.Bits())
+		if typ.Kind() >= reflect.Uint {
+			if f < 0 || f >= limit {
+				fail()
+			}
+			rv.SetUint(uint64(f))
+		} else {
+			if f < -limit/2 || f >= limit/2 {
+				fail()
+			}
+			rv.SetInt(int64(f))
+		}
+	case reflect.Float32, reflect.Float64:
+		f, ok := number(v)
+		if !ok {
+			fail()
+		}
+		rv.SetFloat(f)
+	case reflect.Pointer:
+		if nullish {
+			return rv
+		}
+		rv.Set(reflect.New(typ.Elem()))
+		rv.Elem().Set(convertArg(runtime, name, i, v, typ.Elem()))
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		if nullish {
+			return rv
+		}
+		fallthrough
+	default:
+		_, isObject := v.(*goja.Object)
+		_, isFunc := goja.AssertFunction(v)
+		switch typ.Kind() {
+		case reflect.Interface:
+		case reflect.Func:
+			if !isFunc {
+				fail()
+			}
+		case reflect.Slice, reflect.Array:
+			if !isObject || v.ExportType().Kind() != reflect.Slice {
+				fail()
+			}
+		default:
+			if !isObject || isFunc {
+				fail()
+			}
+		}
+		if err := runtime.ExportTo(v, rv.Addr().Interface()); err != nil {
+			panic(runtime.NewTypeError("%s: argument %d must be %s: %v", name, i+1, describeType(typ), err))
+		}
+	}
+	return rv
+}
+
+func number(v goja.Value) (float64, bool) {
+	switch n := v.Export().(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// describeType names the JS values expected for typ in TypeErrors.
+func describeType(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Func:
+		return "a function"
+	case reflect.Pointer:
+		return describeType(typ.Elem())
+	default:
+		return "an object"
+	}
+}
+
+// describeValue names the type of v the way typeof does, telling null and
+// arrays apart.
+func describeValue(v goja.Value) string {
+	switch {
+	case goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	if _, ok := goja.AssertFunction(v); ok {
+		return "function"
+	}
+	switch v.ExportType().Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// lowerCamel lowers the leading upper case run of the Go name s, keeping the
+// last letter of an initialism followed by a word: HTTPStatus is httpStatus.
+func lowerCamel(s string) string {
+	runes := []rune(s)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) {
+		n--
+	}
+	return strings.ToLower(string(runes[:n])) + string(runes[n:])
+}
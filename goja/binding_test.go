@@ -0,0 +1,269 @@
+package goja
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindUser struct {
+	ID   string
+	Name string
+	Age  int
+}
+
+func (u *bindUser) Rename(name string) { u.Name = name }
+
+type userNotFoundError struct {
+	ID string
+}
+
+func (e *userNotFoundError) Error() string {
+	return fmt.Sprintf("user %s not found", e.ID)
+}
+
+type userStore interface {
+	GetUser(id string) (*bindUser, error)
+	Count() int
+}
+
+type memoryUserStore struct {
+	users map[string]*bindUser
+}
+
+func (s *memoryUserStore) GetUser(id string) (*bindUser, error) {
+	if u, ok := s.users[id]; ok {
+		return u, nil
+	}
+	return nil, &userNotFoundError{ID: id}
+}
+
+func (s *memoryUserStore) Count() int { return len(s.users) }
+
+func (s *memoryUserStore) PutUser(u *bindUser) error {
+	if u.ID == "" {
+		return errors.New("id is required")
+	}
+	s.users[u.ID] = u
+	return nil
+}
+
+func (s *memoryUserStore) Sum(base float64, values ...int) float64 {
+	for _, v := range values {
+		base += float64(v)
+	}
+	return base
+}
+
+func (s *memoryUserStore) HTTPStatus(ok bool) uint16 {
+	if ok {
+		return 200
+	}
+	return 500
+}
+
+func (s *memoryUserStore) Map(items []string, fn func(string) string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, fn(item))
+	}
+	return out
+}
+
+func (s *memoryUserStore) Reset() { clear(s.users) }
+
+type echoer struct{}
+
+func (echoer) Echo(v int) int { return v }
+
+func (echoer) EchoU(v uint64) uint64 { return v }
+
+func (echoer) Cycle() any {
+	m := map[string]any{}
+	m["self"] = m
+	return m
+}
+
+func newTestUserStore() *memoryUserStore {
+	return &memoryUserStore{users: map[string]*bindUser{
+		"1": {ID: "1", Name: "alice", Age: 30},
+	}}
+}
+
+func bindTestUserStore(t *testing.T) *goja.Runtime {
+	vm := goja.New()
+	store, err := Bind(vm, newTestUserStore(), "GetUser", "PutUser", "Count", "Sum", "HTTPStatus", "Map")
+	require.NoError(t, err)
+	require.NoError(t, vm.Set("store", store))
+	return vm
+}
+
+func TestBind(t *testing.T) {
+	vm := bindTestUserStore(t)
+
+	result, err := WrapRun(context.Background(), vm, runString(`
+store.putUser({ID: "2", Name: "bob", Age: 20});
+[
+	store.getUser("1").Name,
+	store.getUser("2").Age,
+	store.count(),
+	store.sum(0.5, 1, 2, 3),
+	store.httpStatus(true),
+	store.map(["a", "b"], function (s) { return s.toUpperCase(); }).join(""),
+	typeof store.reset,
+	Object.keys(store).sort().join(","),
+]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		"alice", int64(20), int64(2), 6.5, int64(200), "AB", "undefined",
+		"count,getUser,httpStatus,map,putUser,sum",
+	}, result.Value.Export())
+
+	// 绑定的方法不能被脚本替换
+	result, err = WrapRun(context.Background(), vm, runString(`store.count = function () { return 0; }; store.count()`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Value.Export())
+
+	// 返回值被复制为普通的 JS 数据，不能调用未绑定的方法，修改也不影响 Go 的值
+	result, err = WrapRun(context.Background(), vm, runString(`
+var u = store.getUser("1");
+u.Name = "mallory";
+[typeof u.Rename, typeof u.rename, Object.keys(u).join(","), store.getUser("1").Name]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"undefined", "undefined", "ID,Name,Age", "alice"}, result.Value.Export())
+
+	// 复制的数据可以再传回 Go
+	result, err = WrapRun(context.Background(), vm, runString(`var u = store.getUser("1"); u.ID = "3"; store.putUser(u); store.getUser("3").Name`))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", result.Value.Export())
+}
+
+func TestBindTypeErrors(t *testing.T) {
+	vm := bindTestUserStore(t)
+
+	for src, msg := range map[string]string{
+		`store.getUser(1)`:              "getUser: argument 1 must be a string, got number",
+		`store.getUser()`:               "getUser: argument 1 must be a string, got undefined",
+		`store.getUser("1", "2")`:       "getUser: expects 1 argument, got 2",
+		`store.count(1)`:                "count: expects 0 arguments, got 1",
+		`store.sum(1, 2, 2.5)`:          "sum: argument 3 must be an integer, got number",
+		`store.sum("1")`:                "sum: argument 1 must be a number, got string",
+		`store.httpStatus(null)`:        "httpStatus: argument 1 must be a boolean, got null",
+		`store.putUser("bob")`:          "putUser: argument 1 must be an object, got string",
+		`store.map({}, function () {})`: "map: argument 1 must be an array, got object",
+		`store.map(["a"], "upper")`:     "map: argument 2 must be a function, got string",
+		`store.putUser(function () {})`: "putUser: argument 1 must be an object, got function",
+	} {
+		_, err := WrapRun(context.Background(), vm, runString(src))
+		var exception *ExceptionError
+		if assert.ErrorAs(t, err, &exception, src) {
+			assert.Equal(t, "TypeError", exception.Name, src)
+			assert.Equal(t, msg, exception.Message, src)
+		}
+	}
+
+	// 超出范围的浮点数在转换前就报错，而不是得到未定义的结果
+	echo, err := Bind(vm, echoer{}, "Echo", "EchoU", "Cycle")
+	require.NoError(t, err)
+	require.NoError(t, vm.Set("echoer", echo))
+	for src, msg := range map[string]string{
+		`echoer.echo(1e19)`:     "echo: argument 1 must be an integer, got number",
+		`echoer.echo(-1e19)`:    "echo: argument 1 must be an integer, got number",
+		`echoer.echo(Infinity)`: "echo: argument 1 must be an integer, got number",
+		`echoer.echoU(1e20)`:    "echoU: argument 1 must be a non negative integer, got number",
+		`echoer.echoU(2 ** 64)`: "echoU: argument 1 must be a non negative integer, got number",
+		`echoer.echoU(NaN)`:     "echoU: argument 1 must be a non negative integer, got number",
+		`echoer.echoU(-1)`:      "echoU: argument 1 must be a non negative integer, got number",
+	} {
+		_, err := WrapRun(context.Background(), vm, runString(src))
+		var exception *ExceptionError
+		if assert.ErrorAs(t, err, &exception, src) {
+			assert.Equal(t, "TypeError", exception.Name, src)
+			assert.Equal(t, msg, exception.Message, src)
+		}
+	}
+	result, err := WrapRun(context.Background(), vm, runString(`[echoer.echo(-(2 ** 63)), echoer.echoU(2 ** 63)]`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{float64(math.MinInt64), float64(1 << 63)}, result.Value.Export())
+
+	// 循环的返回值报错，而不是栈溢出
+	_, err = WrapRun(context.Background(), vm, runString(`echoer.cycle()`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "cycle: cannot return map[string]interface {}: cyclic value", exception.Message)
+
+	// 可以在 JS 中 catch
+	result, err = WrapRun(context.Background(), vm, runString(`
+try {
+	store.getUser(1);
+} catch (e) {
+	e instanceof TypeError;
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Value.Export())
+}
+
+func TestBindGoErrors(t *testing.T) {
+	vm := bindTestUserStore(t)
+
+	_, err := WrapRun(context.Background(), vm, runString(`store.getUser("404")`))
+	var notFound *userNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "404", notFound.ID)
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "GoError", exception.Name)
+	assert.Equal(t, "user 404 not found", exception.Message)
+
+	// JS 中可以 catch Go 的错误
+	result, err := WrapRun(context.Background(), vm, runString(`
+try {
+	store.putUser({Name: "nobody"});
+} catch (e) {
+	e.message;
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "id is required", result.Value.String())
+}
+
+func TestBindInterface(t *testing.T) {
+	vm := goja.New()
+	var store userStore = newTestUserStore()
+
+	// 只能绑定接口中的方法
+	_, err := Bind(vm, store, "PutUser")
+	assert.ErrorContains(t, err, "has no exported method PutUser")
+
+	obj, err := Bind(vm, store, "GetUser", "Count")
+	require.NoError(t, err)
+	require.NoError(t, vm.Set("store", obj))
+	result, err := WrapRun(context.Background(), vm, runString(`store.getUser("1").Name + store.count()`))
+	require.NoError(t, err)
+	assert.Equal(t, "alice1", result.Value.String())
+
+	_, err = Bind[userStore](vm, nil, "Count")
+	assert.Error(t, err)
+}
+
+func TestLowerCamel(t *testing.T) {
+	for name, want := range map[string]string{
+		"Get":        "get",
+		"GetUser":    "getUser",
+		"ID":         "id",
+		"HTTPStatus": "httpStatus",
+		"UserID":     "userID",
+		"X":          "x",
+	} {
+		assert.Equal(t, want, lowerCamel(name), name)
+	}
+}
@@ -0,0 +1,154 @@
+package goja
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// CacheStats is a snapshot of the counters of a ProgramCache.
+type CacheStats struct {
+	Hits, Misses, Evictions uint64
+	// Entries and Bytes are the cached programs and the size of their
+	// sources and source maps.
+	Entries, Bytes int
+}
+
+// ProgramCache caches compiled programs by the hash of their name and
+// source, evicting the least recently used ones. A ProgramCache is safe for
+// concurrent use and its programs may run on any runtime.
+type ProgramCache struct {
+	maxEntries int
+	maxBytes   int
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, most recently used first
+	items map[[sha256.Size]byte]*list.Element
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	program *goja.Program
+	size    int
+}
+
+// NewProgramCache returns a cache holding at most maxEntries programs whose
+// sources total at most maxBytes, 0 means no limit. Sources larger than
+// maxBytes are compiled but not cached.
+func NewProgramCache(maxEntries, maxBytes int) *ProgramCache {
+	return &ProgramCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+// DefaultProgramCache is the cache used by RunCached.
+var DefaultProgramCache = NewProgramCache(1024, 64<<20)
+
+// Compile returns the program compiled from src, name is the file name
+// reported in stack traces. A sourceMappingURL comment in src is ignored
+// rather than read from the local filesystem.
+func (c *ProgramCache) Compile(name, src string) (*goja.Program, error) {
+	return c.compile(name, src, nil)
+}
+
+// CompileWithSourceMap is Compile for a generated src, the stack traces of
+// the program report the original positions given by sourceMap.
+func (c *ProgramCache) CompileWithSourceMap(name, src string, sourceMap []byte) (*goja.Program, error) {
+	return c.compile(name, src, sourceMap)
+}
+
+func (c *ProgramCache) compile(name, src string, sourceMap []byte) (*goja.Program, error) {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(src))
+	if sourceMap != nil {
+		h.Write([]byte{0})
+		h.Write(sourceMap)
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).program, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// compile outside of the lock, concurrent misses of the same source
+	// compile it twice and keep one
+	var (
+		program *goja.Program
+		err     error
+	)
+	if sourceMap != nil {
+		program, err = CompileWithSourceMap(name, src, sourceMap)
+	} else {
+		program, err = compileWithoutSourceMap(name, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := len(src) + len(sourceMap)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return program, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*cacheEntry).program, nil
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, program: program, size: size})
+	c.stats.Entries++
+	c.stats.Bytes += size
+	for (c.maxEntries > 0 && c.stats.Entries > c.maxEntries) || (c.maxBytes > 0 && c.stats.Bytes > c.maxBytes) {
+		c.evict(c.lru.Back())
+	}
+	return program, nil
+}
+
+func (c *ProgramCache) evict(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.stats.Entries--
+	c.stats.Bytes -= entry.size
+	c.stats.Evictions++
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *ProgramCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Run compiles src through the cache and runs it on runtime with WrapRun.
+// Syntax errors are returned as an *ExceptionError named SyntaxError, which
+// wraps the *goja.CompilerSyntaxError.
+func (c *ProgramCache) Run(ctx context.Context, runtime *goja.Runtime, name, src string) (Result, error) {
+	return WrapRun(ctx, runtime, func(runtime *goja.Runtime) (goja.Value, error) {
+		program, err := c.Compile(name, src)
+		if err != nil {
+			return nil, err
+		}
+		return runtime.RunProgram(program)
+	})
+}
+
+// RunCached is DefaultProgramCache.Run.
+func RunCached(ctx context.Context, runtime *goja.Runtime, name, src string) (Result, error) {
+	return DefaultProgramCache.Run(ctx, runtime, name, src)
+}
@@ -0,0 +1,136 @@
+package goja
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramCache(t *testing.T) {
+	cache := NewProgramCache(2, 0)
+	p1, err := cache.Compile("a.js", "1 + 1")
+	require.NoError(t, err)
+	p2, err := cache.Compile("a.js", "1 + 1")
+	require.NoError(t, err)
+	assert.Same(t, p1, p2)
+
+	// 同样的源码但不同的文件名是不同的 program，否则堆栈里的文件名会错
+	p3, err := cache.Compile("b.js", "1 + 1")
+	require.NoError(t, err)
+	assert.NotSame(t, p1, p3)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 2, Bytes: 10}, cache.Stats())
+
+	// a.js 最近被使用过，淘汰的是 b.js
+	_, err = cache.Compile("a.js", "1 + 1")
+	require.NoError(t, err)
+	_, err = cache.Compile("c.js", "2 + 2")
+	require.NoError(t, err)
+	p4, err := cache.Compile("a.js", "1 + 1")
+	require.NoError(t, err)
+	assert.Same(t, p1, p4)
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 3, Evictions: 1, Entries: 2, Bytes: 10}, cache.Stats())
+}
+
+func TestProgramCacheMaxBytes(t *testing.T) {
+	cache := NewProgramCache(0, 10)
+	_, err := cache.Compile("a.js", "1 + 1")
+	require.NoError(t, err)
+	_, err = cache.Compile("b.js", "2 + 2")
+	require.NoError(t, err)
+	_, err = cache.Compile("c.js", "3 + 3")
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Misses: 3, Evictions: 1, Entries: 2, Bytes: 10}, cache.Stats())
+
+	// 超过上限的源码不会被缓存
+	_, err = cache.Compile("big.js", strings.Repeat(" ", 10)+"1")
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Misses: 4, Evictions: 1, Entries: 2, Bytes: 10}, cache.Stats())
+}
+
+func TestProgramCacheRun(t *testing.T) {
+	cache := NewProgramCache(0, 0)
+	vm := goja.New()
+	for i := 0; i < 3; i++ {
+		result, err := cache.Run(context.Background(), vm, "sum.js", `[1, 2, 3].reduce((a, b) => a + b)`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), result.Value.Export())
+	}
+	assert.Equal(t, uint64(2), cache.Stats().Hits)
+
+	// 同一个 program 可以在其他 runtime 上执行
+	result, err := cache.Run(context.Background(), goja.New(), "sum.js", `[1, 2, 3].reduce((a, b) => a + b)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.Value.Export())
+
+	// 中断依然按照 WrapRun 的方式工作
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err = cache.Run(ctx, vm, "loop.js", loopScript)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+
+	_, err = cache.Run(context.Background(), vm, "throw.js", `throw new Error("boom")`)
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "throw.js", exception.File)
+
+	// 语法错误和 RunString 一样是 SyntaxError
+	_, err = cache.Run(context.Background(), vm, "bad.js", "1;\nvar =")
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "SyntaxError", exception.Name)
+	assert.Equal(t, "bad.js: Line 2:5 Unexpected token = (and 1 more errors)", exception.Message)
+	assert.ErrorAs(t, err, new(*goja.CompilerSyntaxError))
+}
+
+func TestRunCached(t *testing.T) {
+	vm := goja.New()
+	result, err := RunCached(context.Background(), vm, "hello.js", `"hello " + "world"`)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", result.Value.String())
+}
+
+func TestProgramCacheConcurrent(t *testing.T) {
+	cache := NewProgramCache(8, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			src := fmt.Sprintf("%d * 2", i%10)
+			result, err := cache.Run(context.Background(), goja.New(), "mul.js", src)
+			if assert.NoError(t, err) {
+				assert.Equal(t, int64(i%10*2), result.Value.Export())
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := cache.Stats()
+	assert.Equal(t, uint64(50), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Entries, 8)
+}
+
+func BenchmarkRunString(b *testing.B) {
+	vm := goja.New()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.RunString(`[1, 2, 3].map(v => v * 2).join(",")`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRunCached(b *testing.B) {
+	vm := goja.New()
+	cache := NewProgramCache(0, 0)
+	for i := 0; i < b.N; i++ {
+		if _, err := cache.Run(context.Background(), vm, "bench.js", `[1, 2, 3].map(v => v * 2).join(",")`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
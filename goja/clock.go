@@ -0,0 +1,80 @@
+package goja
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// Clock is the time source of the timers of a Loop.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a channel receiving once d elapsed, and a function
+	// releasing the timer.
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+// FakeClock is a Clock for tests. Its time only moves with Advance, and when
+// a Loop waits for its next timer: the clock then jumps to it right away, so
+// that a script sleeping for an hour finishes at once, its timers firing in
+// order. The operations started by Loop.Go do not hold the clock back.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// NewTimer advances the clock by d and returns a fired timer.
+func (c *FakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.Advance(d)
+	fired := make(chan time.Time, 1)
+	fired <- c.Now()
+	return fired, func() bool { return false }
+}
+
+// RunWithFakeClock runs f on a new Loop of runtime driven by clock, which Date
+// follows as well, so that scripts depending on setTimeout and Date run
+// instantly and give the same result on every run.
+func RunWithFakeClock(
+	ctx context.Context,
+	runtime *goja.Runtime,
+	clock *FakeClock,
+	f func(runtime *goja.Runtime) (goja.Value, error),
+) (Result, error) {
+	loop, err := NewLoop(runtime)
+	if err != nil {
+		return Result{}, err
+	}
+	loop.SetClock(clock)
+	runtime.SetTimeSource(clock.Now)
+	return loop.Run(ctx, f)
+}
@@ -0,0 +1,49 @@
+package goja
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	begin := time.Now()
+	result, err := RunWithFakeClock(context.Background(), goja.New(), clock, runString(`
+var log = [];
+var t0 = Date.now();
+function sleep(ms) {
+	return new Promise(function (resolve) { setTimeout(resolve, ms); });
+}
+setTimeout(function () { log.push("late " + (Date.now() - t0)); }, 3600 * 1000);
+var n = 0;
+var id = setInterval(function () {
+	log.push("tick " + (Date.now() - t0));
+	if (++n == 2) {
+		clearInterval(id);
+	}
+}, 60 * 1000);
+(async function () {
+	await sleep(30 * 1000);
+	log.push("slept " + (Date.now() - t0));
+	return log;
+})();
+`))
+	require.NoError(t, err)
+	// 一个小时的定时器立即完成，且按时间顺序触发
+	assert.Less(t, time.Since(begin), time.Second)
+	assert.Equal(t, []any{"slept 30000", "tick 60000", "tick 120000", "late 3600000"}, result.Value.Export())
+	assert.Equal(t, start.Add(time.Hour), clock.Now())
+
+	// Date 同样使用假时钟
+	clock.Advance(time.Minute)
+	result, err = RunWithFakeClock(context.Background(), goja.New(), clock, runString(`new Date().toISOString()`))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-01T01:01:00.000Z", result.Value.String())
+}
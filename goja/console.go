@@ -0,0 +1,400 @@
+package goja
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// ConsoleOptions configures a Console.
+type ConsoleOptions struct {
+	// Handler receives a record for every console call, the handler of
+	// slog.Default when nil.
+	Handler slog.Handler
+	// MaxBufferBytes caps the output buffered per execution, 64KB when 0.
+	// The lines past it are dropped from the buffer, not from Handler.
+	MaxBufferBytes int
+}
+
+// ConsoleOutput is the console output of an execution.
+type ConsoleOutput struct {
+	// Text holds the lines written, each prefixed with its level.
+	Text string
+	// Truncated reports whether lines were dropped for MaxBufferBytes.
+	Truncated bool
+}
+
+// Console installs console.log, info, warn, error, debug, time, timeLog,
+// timeEnd and table into a runtime and routes them to a slog.Handler. The
+// records carry the script name and the execution ID given to Begin, and the
+// JS position of the call as "caller". console.log is logged at the info
+// level.
+//
+// Arguments are formatted as Node.js does: a leading string may hold the
+// %s, %d, %i, %f, %j, %o, %O and %c directives, objects are written as JSON.
+type Console struct {
+	runtime *goja.Runtime
+	handler slog.Handler
+	max     int
+	exec    *consoleExecution // nil out of Begin and End
+
+	// captured when created, scripts may replace or delete the globals
+	stringify goja.Callable // nil without JSON.stringify
+	errorCtor *goja.Object  // nil without Error
+}
+
+type consoleExecution struct {
+	ctx         context.Context
+	script      string
+	executionID string
+	buf         strings.Builder
+	truncated   bool
+	timers      map[string]time.Time
+}
+
+// NewConsole returns a Console for runtime and sets its console object.
+func NewConsole(runtime *goja.Runtime, opts ConsoleOptions) (*Console, error) {
+	c := &Console{
+		runtime: runtime,
+		handler: opts.Handler,
+		max:     opts.MaxBufferBytes,
+	}
+	if c.handler == nil {
+		c.handler = slog.Default().Handler()
+	}
+	if c.max <= 0 {
+		c.max = 64 << 10
+	}
+	if json, ok := runtime.Get("JSON").(*goja.Object); ok {
+		c.stringify, _ = goja.AssertFunction(json.Get("stringify"))
+	}
+	if ctor, ok := runtime.Get("Error").(*goja.Object); ok {
+		c.errorCtor = ctor
+	}
+
+	console := runtime.NewObject()
+	for name, fn := range map[string]func(goja.FunctionCall) goja.Value{
+		"log":     c.logFunc(slog.LevelInfo),
+		"info":    c.logFunc(slog.LevelInfo),
+		"warn":    c.logFunc(slog.LevelWarn),
+		"error":   c.logFunc(slog.LevelError),
+		"debug":   c.logFunc(slog.LevelDebug),
+		"time":    c.time,
+		"timeLog": c.timeLog(false),
+		"timeEnd": c.timeLog(true),
+		"table":   c.table,
+	} {
+		if err := console.Set(name, fn); err != nil {
+			return nil, errors.Wrapf(err, "failed to set console.%s", name)
+		}
+	}
+	if err := runtime.Set("console", console); err != nil {
+		return nil, errors.Wrap(err, "failed to set console")
+	}
+	return c, nil
+}
+
+// Begin starts an execution, the console calls until End are attributed to
+// script and executionID and buffered. ctx is passed to the handler.
+func (c *Console) Begin(ctx context.Context, script, executionID string) {
+	c.exec = &consoleExecution{
+		ctx:         ctx,
+		script:      script,
+		executionID: executionID,
+		timers:      map[string]time.Time{},
+	}
+}
+
+// End ends the execution started by Begin and returns its output.
+func (c *Console) End() ConsoleOutput {
+	exec := c.exec
+	c.exec = nil
+	if exec == nil {
+		return ConsoleOutput{}
+	}
+	return ConsoleOutput{Text: exec.buf.String(), Truncated: exec.truncated}
+}
+
+// Run runs f with WrapRun as the execution executionID of script.
+func (c *Console) Run(
+	ctx context.Context,
+	script, executionID string,
+	f func(runtime *goja.Runtime) (goja.Value, error),
+) (Result, ConsoleOutput, error) {
+	c.Begin(ctx, script, executionID)
+	result, err := WrapRun(ctx, c.runtime, f)
+	return result, c.End(), err
+}
+
+func (c *Console) logFunc(level slog.Level) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		c.emit(level, c.format(call.Arguments))
+		return goja.Undefined()
+	}
+}
+
+func (c *Console) time(call goja.FunctionCall) goja.Value {
+	label := timerLabel(call)
+	if exec := c.exec; exec != nil {
+		if _, ok := exec.timers[label]; ok {
+			c.emit(slog.LevelWarn, fmt.Sprintf("Timer '%s' already exists", label))
+		} else {
+			exec.timers[label] = time.Now()
+		}
+	}
+	return goja.Undefined()
+}
+
+func (c *Console) timeLog(end bool) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		label := timerLabel(call)
+		var (
+			start time.Time
+			ok    bool
+		)
+		if exec := c.exec; exec != nil {
+			start, ok = exec.timers[label]
+			if end {
+				delete(exec.timers, label)
+			}
+		}
+		if !ok {
+			c.emit(slog.LevelWarn, fmt.Sprintf("Timer '%s' does not exist", label))
+			return goja.Undefined()
+		}
+		msg := fmt.Sprintf("%s: %s", label, time.Since(start))
+		if !end && len(call.Arguments) > 1 {
+			msg += " " + c.format(call.Arguments[1:])
+		}
+		c.emit(slog.LevelInfo, msg)
+		return goja.Undefined()
+	}
+}
+
+func timerLabel(call goja.FunctionCall) string {
+	if v := call.Argument(0); !goja.IsUndefined(v) {
+		return v.String()
+	}
+	return "default"
+}
+
+func (c *Console) emit(level slog.Level, msg string) {
+	ctx := context.Background()
+	attrs := make([]slog.Attr, 0, 3)
+	if exec := c.exec; exec != nil {
+		ctx = exec.ctx
+		attrs = append(attrs, slog.String("script", exec.script), slog.String("execution_id", exec.executionID))
+
+		line := level.String() + " " + msg + "\n"
+		if exec.truncated || exec.buf.Len()+len(line) > c.max {
+			exec.truncated = true
+		} else {
+			exec.buf.WriteString(line)
+		}
+	}
+	if !c.handler.Enabled(ctx, level) {
+		return
+	}
+
+	// frame 0 is the console function itself
+	if frames := c.runtime.CaptureCallStack(2, nil); len(frames) > 1 {
+		pos := frames[1].Position()
+		attrs = append(attrs, slog.String("caller", fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)))
+	}
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	record.AddAttrs(attrs...)
+	// a failing handler must not fail the script
+	_ = c.handler.Handle(ctx, record)
+}
+
+// format formats args as console.log does.
+func (c *Console) format(args []goja.Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	rest := args
+	if s, ok := args[0].Export().(string); ok {
+		if len(args) == 1 {
+			return s
+		}
+		rest = args[1:]
+		for i := 0; i < len(s); i++ {
+			if s[i] != '%' || i+1 == len(s) {
+				b.WriteByte(s[i])
+				continue
+			}
+			verb := s[i+1]
+			if verb == '%' {
+				b.WriteByte('%')
+				i++
+				continue
+			}
+			if !strings.ContainsRune("sdifjoOc", rune(verb)) || len(rest) == 0 {
+				b.WriteByte(s[i])
+				continue
+			}
+			arg := rest[0]
+			rest = rest[1:]
+			i++
+			switch verb {
+			case 's':
+				if _, ok := arg.Export().(string); ok {
+					b.WriteString(arg.String())
+				} else {
+					b.WriteString(c.inspect(arg))
+				}
+			case 'd', 'f':
+				b.WriteString(arg.ToNumber().String())
+			case 'i':
+				b.WriteString(c.runtime.ToValue(math.Trunc(arg.ToFloat())).String())
+			case 'j', 'o', 'O':
+				b.WriteString(c.inspect(arg))
+			case 'c':
+				// CSS is meaningless here
+			}
+		}
+	}
+	for i, arg := range rest {
+		if i > 0 || b.Len() > 0 || len(rest) < len(args) {
+			b.WriteByte(' ')
+		}
+		if s, ok := arg.Export().(string); ok {
+			b.WriteString(s)
+		} else {
+			b.WriteString(c.inspect(arg))
+		}
+	}
+	return b.String()
+}
+
+// inspect renders v for the console, objects as JSON.
+func (c *Console) inspect(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if _, isString := v.Export().(string); isString {
+			return strconv.Quote(v.String())
+		}
+		return v.String()
+	}
+	if _, isFunc := goja.AssertFunction(v); isFunc {
+		if name := obj.Get("name"); name != nil && name.String() != "" {
+			return "[Function: " + name.String() + "]"
+		}
+		return "[Function (anonymous)]"
+	}
+	if c.errorCtor != nil && c.runtime.InstanceOf(obj, c.errorCtor) {
+		if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+			return stack.String()
+		}
+		return obj.String()
+	}
+	if c.stringify == nil {
+		return obj.String()
+	}
+	s, err := c.stringify(goja.Undefined(), v)
+	if err != nil || goja.IsUndefined(s) {
+		// cycles and the like
+		return obj.String()
+	}
+	return s.String()
+}
+
+// table writes data as a table, like console.table in Node.js. Values that
+// are not objects are logged as console.log does.
+func (c *Console) table(call goja.FunctionCall) goja.Value {
+	data, ok := call.Argument(0).(*goja.Object)
+	if !ok {
+		c.emit(slog.LevelInfo, c.format(call.Arguments))
+		return goja.Undefined()
+	}
+
+	var filter []string
+	if columns, ok := call.Argument(1).(*goja.Object); ok {
+		for _, k := range columns.Keys() {
+			filter = append(filter, columns.Get(k).String())
+		}
+	}
+
+	header := []string{"(index)"}
+	seen := map[string]int{}
+	hasValues := false
+	var rows []map[string]string
+	for _, key := range data.Keys() {
+		row := map[string]string{"(index)": key}
+		switch v := data.Get(key).(type) {
+		case *goja.Object:
+			if _, isFunc := goja.AssertFunction(v); isFunc {
+				row["Values"] = c.inspect(v)
+				hasValues = true
+				break
+			}
+			for _, col := range v.Keys() {
+				if _, ok := seen[col]; !ok {
+					seen[col] = len(header)
+					header = append(header, col)
+				}
+				row[col] = c.inspect(v.Get(col))
+			}
+		default:
+			row["Values"] = c.inspect(v)
+			hasValues = true
+		}
+		rows = append(rows, row)
+	}
+	if filter != nil {
+		header = append([]string{"(index)"}, filter...)
+	}
+	if hasValues {
+		header = append(header, "Values")
+	}
+
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = utf8.RuneCountInString(h)
+		for _, row := range rows {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[h]))
+		}
+	}
+	var b strings.Builder
+	line := func(left, mid, right string) {
+		b.WriteString(left)
+		for i, w := range widths {
+			if i > 0 {
+				b.WriteString(mid)
+			}
+			b.WriteString(strings.Repeat("─", w+2))
+		}
+		b.WriteString(right + "\n")
+	}
+	cells := func(values func(i int) string) {
+		for i, w := range widths {
+			v := values(i)
+			b.WriteString("│ " + v + strings.Repeat(" ", w-utf8.RuneCountInString(v)) + " ")
+		}
+		b.WriteString("│\n")
+	}
+	line("┌", "┬", "┐")
+	cells(func(i int) string { return header[i] })
+	line("├", "┼", "┤")
+	for _, row := range rows {
+		cells(func(i int) string { return row[header[i]] })
+	}
+	line("└", "┴", "┘")
+	c.emit(slog.LevelInfo, strings.TrimSuffix(b.String(), "\n"))
+	return goja.Undefined()
+}
@@ -0,0 +1,240 @@
+package goja
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedLog struct {
+	Level   slog.Level
+	Message string
+	Attrs   map[string]string
+}
+
+// recordHandler 记录收到的日志，低于 level 的日志不会收到
+type recordHandler struct {
+	level slog.Level
+	mu    sync.Mutex
+	logs  []recordedLog
+}
+
+func (h *recordHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	log := recordedLog{Level: r.Level, Message: r.Message, Attrs: map[string]string{}}
+	r.Attrs(func(a slog.Attr) bool {
+		log.Attrs[a.Key] = a.Value.String()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logs = append(h.logs, log)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *recordHandler) messages() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var msgs []string
+	for _, log := range h.logs {
+		msgs = append(msgs, log.Message)
+	}
+	return msgs
+}
+
+func newTestConsole(t *testing.T, opts ConsoleOptions) (*goja.Runtime, *Console, *recordHandler) {
+	handler := &recordHandler{level: slog.LevelInfo}
+	opts.Handler = handler
+	vm := goja.New()
+	console, err := NewConsole(vm, opts)
+	require.NoError(t, err)
+	return vm, console, handler
+}
+
+func TestConsole(t *testing.T) {
+	_, console, handler := newTestConsole(t, ConsoleOptions{})
+
+	_, output, err := console.Run(context.Background(), "job.js", "exec-1", func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunScript("job.js", `function main() {
+	console.log("hello", "world", 1, true, null, undefined);
+	console.info("user %s is %d years old", "bob", 42.5, "extra");
+	console.warn({a: 1, b: [1, 2]});
+	console.error(new Error("boom").message);
+	console.debug("hidden from the handler");
+}
+main();
+`)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, handler.logs, 4)
+	assert.Equal(t, recordedLog{
+		Level:   slog.LevelInfo,
+		Message: "hello world 1 true null undefined",
+		Attrs:   map[string]string{"script": "job.js", "execution_id": "exec-1", "caller": "job.js:2:13"},
+	}, handler.logs[0])
+	assert.Equal(t, []string{
+		"hello world 1 true null undefined",
+		"user bob is 42.5 years old extra",
+		`{"a":1,"b":[1,2]}`,
+		"boom",
+	}, handler.messages())
+	assert.Equal(t, slog.LevelWarn, handler.logs[2].Level)
+	assert.Equal(t, slog.LevelError, handler.logs[3].Level)
+
+	// 缓冲中包含所有级别的输出
+	assert.Equal(t, `INFO hello world 1 true null undefined
+INFO user bob is 42.5 years old extra
+WARN {"a":1,"b":[1,2]}
+ERROR boom
+DEBUG hidden from the handler
+`, output.Text)
+	assert.False(t, output.Truncated)
+}
+
+func TestConsoleFormat(t *testing.T) {
+	vm, console, _ := newTestConsole(t, ConsoleOptions{})
+	console.Begin(context.Background(), "format.js", "")
+	defer console.End()
+
+	for src, want := range map[string]string{
+		`["100%"]`:                              "100%",
+		`["%s"]`:                                "%s",
+		`["%s%%", 50]`:                          "50%",
+		`["%i items", 3.9]`:                     "3 items",
+		`["%f", "1.5"]`:                         "1.5",
+		`["%d", "x"]`:                           "NaN",
+		`["%j and %o", {a: 1}, [1]]`:            `{"a":1} and [1]`,
+		`["%c styled", "color: red"]`:           " styled",
+		`["%s %s", "only one"]`:                 "only one %s",
+		`["%x", 1]`:                             "%x 1",
+		`[1, "two", [3]]`:                       `1 two [3]`,
+		`[function named() {}, function () {}]`: "[Function: named] [Function (anonymous)]",
+		`[{s: "quoted"}]`:                       `{"s":"quoted"}`,
+		`[(function () { var o = {}; o.self = o; return o; })()]`: "[object Object]",
+	} {
+		v, err := vm.RunString(src)
+		require.NoError(t, err, src)
+		var args []goja.Value
+		obj := v.ToObject(vm)
+		for _, k := range obj.Keys() {
+			args = append(args, obj.Get(k))
+		}
+		assert.Equal(t, want, console.format(args), src)
+	}
+}
+
+func TestConsoleReplacedGlobals(t *testing.T) {
+	vm, console, handler := newTestConsole(t, ConsoleOptions{})
+
+	// 脚本删除或替换 JSON 和 Error 后 console 依然可用
+	_, _, err := console.Run(context.Background(), "job.js", "", runString(`
+delete globalThis.JSON;
+Error = 1;
+console.log({a: 1});
+console.log(new RangeError("boom").message);
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, "boom"}, handler.messages())
+
+	// 创建时就没有 JSON 的 runtime 中，对象按 String 输出
+	_, err = vm.RunString(`delete globalThis.Error`)
+	require.NoError(t, err)
+	console, err = NewConsole(vm, ConsoleOptions{Handler: handler})
+	require.NoError(t, err)
+	_, _, err = console.Run(context.Background(), "job.js", "", runString(`console.log({a: 1}, [1, 2])`))
+	require.NoError(t, err)
+	assert.Equal(t, "[object Object] 1,2", handler.messages()[2])
+}
+
+func TestConsoleTimeAndTable(t *testing.T) {
+	_, console, handler := newTestConsole(t, ConsoleOptions{})
+
+	_, _, err := console.Run(context.Background(), "table.js", "exec-2", runString(`
+console.time("load");
+console.timeLog("load", "halfway");
+console.timeEnd("load");
+console.timeEnd("load");
+console.time();
+console.time();
+console.table([{name: "alice", age: 30}, {name: "bob", city: "paris"}]);
+console.table({x: 1, y: "two"});
+console.table([{a: 1, b: 2}], ["b"]);
+console.table("not tabular");
+`))
+	require.NoError(t, err)
+
+	msgs := handler.messages()
+	require.Len(t, msgs, 8)
+	assert.Regexp(t, `^load: \S+ halfway$`, msgs[0])
+	assert.Regexp(t, `^load: \S+$`, msgs[1])
+	assert.Equal(t, "Timer 'load' does not exist", msgs[2])
+	assert.Equal(t, "Timer 'default' already exists", msgs[3])
+	assert.Equal(t, strings.TrimSpace(`
+┌─────────┬─────────┬─────┬─────────┐
+│ (index) │ name    │ age │ city    │
+├─────────┼─────────┼─────┼─────────┤
+│ 0       │ "alice" │ 30  │         │
+│ 1       │ "bob"   │     │ "paris" │
+└─────────┴─────────┴─────┴─────────┘
+`), msgs[4])
+	assert.Equal(t, strings.TrimSpace(`
+┌─────────┬────────┐
+│ (index) │ Values │
+├─────────┼────────┤
+│ x       │ 1      │
+│ y       │ "two"  │
+└─────────┴────────┘
+`), msgs[5])
+	assert.Equal(t, strings.TrimSpace(`
+┌─────────┬───┐
+│ (index) │ b │
+├─────────┼───┤
+│ 0       │ 2 │
+└─────────┴───┘
+`), msgs[6])
+	assert.Equal(t, "not tabular", msgs[7])
+}
+
+func TestConsoleBuffer(t *testing.T) {
+	_, console, handler := newTestConsole(t, ConsoleOptions{MaxBufferBytes: 32})
+
+	_, output, err := console.Run(context.Background(), "spam.js", "exec-3", runString(`
+for (var i = 0; i < 10; i++) {
+	console.log("line " + i);
+}
+`))
+	require.NoError(t, err)
+	// 超出上限的行不会写入缓冲，但依然会交给 handler
+	assert.Equal(t, "INFO line 0\nINFO line 1\n", output.Text)
+	assert.True(t, output.Truncated)
+	assert.Len(t, handler.logs, 10)
+
+	// 每次执行有自己的缓冲
+	_, output, err = console.Run(context.Background(), "spam.js", "exec-4", runString(`console.log("again")`))
+	require.NoError(t, err)
+	assert.Equal(t, ConsoleOutput{Text: "INFO again\n"}, output)
+	assert.Equal(t, "exec-4", handler.logs[10].Attrs["execution_id"])
+
+	// 执行之外的调用不带执行的信息，也不会被缓冲
+	vm := goja.New()
+	outside, err := NewConsole(vm, ConsoleOptions{Handler: handler})
+	require.NoError(t, err)
+	_, err = vm.RunScript("outside.js", `console.log("outside")`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"caller": "outside.js:1:12"}, handler.logs[11].Attrs)
+	assert.Equal(t, ConsoleOutput{}, outside.End())
+}
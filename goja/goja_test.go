@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
-	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
@@ -83,38 +82,6 @@ func TestInterrupt(t *testing.T) {
 	}
 }
 
-// 结合上述特点，封装一个方法来实现
-func WrapRun(
-	ctx context.Context,
-	runtime *goja.Runtime,
-	f func(runtime *goja.Runtime) (goja.Value, error),
-) (result goja.Value, interrupted bool, err error) {
-	if ctx.Err() != nil {
-		return nil, false, errors.Wrap(ctx.Err(), "context already done")
-	}
-
-	// 直接在 f 运行之前保证之前的 Interrupted 标记被清除，此方法内部其实只是执行了一个 atomic.Store 所以成本其实很低
-	runtime.ClearInterrupt()
-
-	stop := context.AfterFunc(ctx, func() {
-		runtime.Interrupt("halt")
-	})
-	defer func() {
-		if !stop() {
-			interrupted = true // TODO: errors.Join 一下 ?
-		}
-	}()
-
-	result, err = f(runtime)
-	if err != nil {
-		if ctx.Err() != nil {
-			return nil, false, errors.Wrap(ctx.Err(), "failed to run")
-		}
-		return nil, false, errors.Wrap(err, "failed to run")
-	}
-	return result, false, nil
-}
-
 func TestWrapRun(t *testing.T) {
 	const SCRIPT = `
 	var i = 0;
@@ -129,26 +96,35 @@ func TestWrapRun(t *testing.T) {
 		start := time.Now()
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 		defer cancel()
-		val, interrupted, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
+		result, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
 			return runtime.RunString(SCRIPT)
 		})
-		assert.Nil(t, val)
-		assert.Equal(t, true, interrupted)
+		assert.Nil(t, result.Value)
+		assert.Equal(t, true, result.Interrupted)
 		assert.ErrorIs(t, err, context.DeadlineExceeded)
 		since := time.Since(start)
 		assert.GreaterOrEqual(t, since, 100*time.Millisecond)
-		assert.Less(t, since, 105*time.Millisecond)
+	}
+
+	{
+		// 中断的时机取决于机器的负载，按步数限制时总是停在同一个位置
+		program, err := CompileMetered("loop.js", SCRIPT)
+		assert.Nil(t, err)
+		result, err := NewLimiter(vm, Limits{MaxSteps: 1000}).Run(context.Background(), runProgram(program))
+		assert.Nil(t, result.Value)
+		assert.ErrorIs(t, err, ErrStepsExceeded)
+		assert.Equal(t, int64(1000), vm.Get("i").ToInteger())
 	}
 
 	{
 		// 可正常执行其他脚本，按预期工作
 		ctx := context.Background()
-		val, interrupted, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
+		result, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
 			return runtime.RunString("1 + 2")
 		})
 		assert.Nil(t, err)
-		assert.Equal(t, "3", val.String())
-		assert.Equal(t, false, interrupted)
+		assert.Equal(t, "3", result.Value.String())
+		assert.Equal(t, false, result.Interrupted)
 	}
 
 	vm.Interrupt("halt")
@@ -156,11 +132,11 @@ func TestWrapRun(t *testing.T) {
 	{
 		// 可正常执行其他脚本，按预期工作
 		ctx := context.Background()
-		val, interrupted, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
+		result, err := WrapRun(ctx, vm, func(runtime *goja.Runtime) (goja.Value, error) {
 			return runtime.RunString("1 + 2")
 		})
 		assert.Nil(t, err)
-		assert.Equal(t, "3", val.String())
-		assert.Equal(t, false, interrupted)
+		assert.Equal(t, "3", result.Value.String())
+		assert.Equal(t, false, result.Interrupted)
 	}
 }
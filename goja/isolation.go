@@ -0,0 +1,235 @@
+package goja
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"github.com/pkg/errors"
+)
+
+// ErrRestoreFailed is returned by Isolation.Restore when the global state
+// could not be fully restored, the runtime should then be discarded.
+var ErrRestoreFailed = errors.New("goja: failed to restore the global state")
+
+// Isolation keeps the executions on a runtime from seeing each other's
+// globals. It snapshots the global state when created, after the base
+// scripts ran, and Restore brings the runtime back to it.
+//
+// The snapshot holds the own properties, symbols included, and the
+// prototype of the global object, of the builtins and of their prototypes.
+// Restore deletes the properties added since, such as implicit globals and
+// globalThis.x, and puts back the ones changed or deleted. The var and
+// function declarations of scripts cannot be deleted from the global object,
+// their value is reset to undefined instead.
+//
+// Top level let, const and class declarations live in the global lexical
+// scope, which goja does not expose, and would persist: RunScript and
+// CompileIsolated run the top level of scripts in a block scope so that they
+// die with the execution, scripts given to Run must not declare them.
+// Isolation undoes the changes of well behaved scripts, it is no defense
+// against hostile ones, for which a Sandbox freezes the builtins.
+type Isolation struct {
+	runtime *goja.Runtime
+	restore goja.Callable
+}
+
+// isolationScript returns the snapshot and restore functions. The builtins
+// they use are captured up front so that scripts replacing them do not break
+// the restore, and the descriptors are copied into objects without prototype
+// so that reading and defining them does not run the getters scripts add to
+// Object.prototype. goja still runs the inherited setters while building
+// descriptors, Restore bounds the time they take. The step counter of
+// Limiters, defined on first use, is kept.
+const isolationScript = `(function (intrinsics, stepFunc) {
+	"use strict";
+	var ownKeys = Reflect.ownKeys, getDesc = Object.getOwnPropertyDescriptor,
+		define = Object.defineProperty, deleteProperty = Reflect.deleteProperty,
+		getProto = Object.getPrototypeOf, setProto = Object.setPrototypeOf,
+		apply = Reflect.apply, is = Object.is, create = Object.create, String_ = String, Map_ = Map,
+		mapHas = Map.prototype.has, mapSet = Map.prototype.set, mapForEach = Map.prototype.forEach;
+
+	// descriptor returns the own property descriptor of obj without prototype
+	function descriptor(obj, key) {
+		var desc = getDesc(obj, key);
+		if (!desc) {
+			return undefined;
+		}
+		var out = create(null), fields = ownKeys(desc);
+		for (var i = 0; i < fields.length; i++) {
+			out[fields[i]] = desc[fields[i]];
+		}
+		return out;
+	}
+
+	// add appends v to list without looking up setters of Array.prototype
+	function add(list, v) {
+		var desc = create(null);
+		desc.value = v;
+		desc.writable = desc.enumerable = desc.configurable = true;
+		define(list, list.length, desc);
+	}
+
+	var objects = [globalThis];
+	intrinsics.forEach(function (name) {
+		var v = globalThis[name];
+		if (v !== null && (typeof v === "object" || typeof v === "function")) {
+			objects.push(v);
+			if (v.prototype !== null && typeof v.prototype === "object") {
+				objects.push(v.prototype);
+			}
+		}
+	});
+
+	var snapshot = [];
+	for (var i = 0; i < objects.length; i++) {
+		var obj = objects[i], props = new Map_(), keys = ownKeys(obj);
+		if (obj === globalThis) {
+			// goja leaves the builtins out of the own keys of the global object
+			// once a script declared a global variable
+			keys = keys.concat(intrinsics, "globalThis", "NaN", "Infinity", "undefined");
+		}
+		for (var j = 0; j < keys.length; j++) {
+			var desc = descriptor(obj, keys[j]);
+			if (desc) {
+				apply(mapSet, props, [keys[j], desc]);
+			}
+		}
+		snapshot[i] = {obj: obj, proto: getProto(obj), props: props};
+	}
+
+	function same(a, b) {
+		return is(a.value, b.value) && a.get === b.get && a.set === b.set &&
+			a.writable === b.writable && a.enumerable === b.enumerable && a.configurable === b.configurable;
+	}
+
+	function restore() {
+		var failed = [];
+		for (var i = 0; i < snapshot.length; i++) {
+			var s = snapshot[i], obj = s.obj, keys = ownKeys(obj);
+			for (var j = 0; j < keys.length; j++) {
+				var key = keys[j];
+				if (apply(mapHas, s.props, [key]) || (obj === globalThis && key === stepFunc) || deleteProperty(obj, key)) {
+					continue;
+				}
+				var desc = descriptor(obj, key);
+				if (desc.writable) {
+					desc = create(null);
+					desc.value = undefined;
+					define(obj, key, desc);
+				} else {
+					add(failed, String_(key));
+				}
+			}
+			apply(mapForEach, s.props, [function (desc, key) {
+				var cur = descriptor(obj, key);
+				if (cur && same(cur, desc)) {
+					return;
+				}
+				try {
+					define(obj, key, desc);
+				} catch (e) {
+					add(failed, String_(key));
+				}
+			}]);
+			if (getProto(obj) !== s.proto) {
+				try {
+					setProto(obj, s.proto);
+				} catch (e) {
+					add(failed, "[[Prototype]]");
+				}
+			}
+		}
+		return failed;
+	}
+	return restore;
+})`
+
+// NewIsolation snapshots the current global state of runtime.
+func NewIsolation(runtime *goja.Runtime) (*Isolation, error) {
+	script, err := runtime.RunString(isolationScript)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile the isolation")
+	}
+	fn, _ := goja.AssertFunction(script)
+	restore, err := fn(goja.Undefined(), runtime.ToValue(intrinsics), runtime.ToValue(stepFunc))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot the global state")
+	}
+	restoreFn, _ := goja.AssertFunction(restore)
+	return &Isolation{runtime: runtime, restore: restoreFn}, nil
+}
+
+// restoreTimeout bounds Restore, which scripts may slow down with setters
+// on Object.prototype.
+const restoreTimeout = time.Second
+
+// Restore brings the global state back to the snapshot. Properties made non
+// configurable or frozen objects may prevent it, Restore then restores what
+// it can and returns an error wrapping ErrRestoreFailed. It also fails when
+// the restore is interrupted after restoreTimeout.
+func (iso *Isolation) Restore() error {
+	ctx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+	defer cancel()
+	var keys []string
+	_, err := WrapRun(ctx, iso.runtime, func(runtime *goja.Runtime) (goja.Value, error) {
+		failed, err := iso.restore(goja.Undefined())
+		if err != nil {
+			return nil, err
+		}
+		return nil, runtime.ExportTo(failed, &keys)
+	})
+	if err != nil {
+		return errors.Wrap(ErrRestoreFailed, err.Error())
+	}
+	if len(keys) > 0 {
+		return errors.Wrapf(ErrRestoreFailed, "cannot restore %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// Run runs f with WrapRun then restores the global state. An error of the
+// restore is returned when f succeeded.
+func (iso *Isolation) Run(ctx context.Context, f func(runtime *goja.Runtime) (goja.Value, error)) (Result, error) {
+	result, err := WrapRun(ctx, iso.runtime, f)
+	if rerr := iso.Restore(); rerr != nil && err == nil {
+		err = rerr
+	}
+	return result, err
+}
+
+// RunScript runs src, compiled with CompileIsolated, with Run.
+func (iso *Isolation) RunScript(ctx context.Context, name, src string) (Result, error) {
+	return iso.Run(ctx, func(runtime *goja.Runtime) (goja.Value, error) {
+		program, err := CompileIsolated(name, src)
+		if err != nil {
+			return nil, err
+		}
+		return runtime.RunProgram(program)
+	})
+}
+
+// CompileIsolated compiles src with its top level statements in a block, so
+// that its let, const, class and function declarations are scoped to the
+// execution, while var declarations stay global and are reset by Restore.
+// The value of the script is kept, the directives such as "use strict" stay
+// first, and the columns following the opening brace on its line are
+// shifted.
+func CompileIsolated(name, src string) (*goja.Program, error) {
+	program, err := goja.Parse(name, src, parser.WithDisableSourceMaps)
+	if err != nil {
+		return nil, err
+	}
+	// the brace opens past the directives, at the start of the source
+	// without them, since the index of a statement starting with a
+	// parenthesis is past it
+	open := "{"
+	start := prologueEnd(src, program.Body, 0)
+	if start > 0 {
+		open = ";{"
+	}
+	// the closing brace goes on its own line, past a trailing line comment
+	return compileWithoutSourceMap(name, src[:start]+open+src[start:]+"\n}")
+}
@@ -0,0 +1,199 @@
+package goja
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const leakScript = `
+var counter = 42;
+function helper() { return "leaked"; }
+implicit = "secret";
+globalThis.explicit = 1;
+globalThis[Symbol.for("leak")] = 1;
+Object.prototype.polluted = true;
+Array.prototype.extra = function () {};
+JSON.stringify = function () { return "hijacked"; };
+Math.PI2 = Math.PI * 2;
+delete globalThis.parseInt;
+quadruple = null;
+`
+
+const checkLeakScript = `
+[
+	typeof counter, typeof helper, typeof implicit, typeof explicit,
+	typeof globalThis[Symbol.for("leak")], typeof ({}).polluted, typeof [].extra,
+	JSON.stringify({a: 1}), typeof Math.PI2, typeof parseInt, quadruple(1),
+].join(" ")
+`
+
+const noLeak = `undefined undefined undefined undefined undefined undefined undefined {"a":1} undefined function 4`
+
+func TestIsolation(t *testing.T) {
+	vm := goja.New()
+	_, err := vm.RunString(`function quadruple(v) { return v * 4; }`)
+	require.NoError(t, err)
+	iso, err := NewIsolation(vm)
+	require.NoError(t, err)
+
+	_, err = iso.Run(context.Background(), runString(leakScript))
+	require.NoError(t, err)
+
+	result, err := iso.Run(context.Background(), runString(checkLeakScript))
+	require.NoError(t, err)
+	assert.Equal(t, noLeak, result.Value.String())
+
+	// 被中断的脚本同样不会留下 i
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = iso.Run(ctx, runString(loopScript))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	result, err = iso.Run(context.Background(), runString(`typeof i`))
+	require.NoError(t, err)
+	assert.Equal(t, "undefined", result.Value.String())
+
+	// 抛出异常的脚本同样会被还原
+	_, err = iso.Run(context.Background(), runString(`leaked = 1; throw new Error("boom")`))
+	assert.ErrorAs(t, err, new(*ExceptionError))
+	result, err = iso.Run(context.Background(), runString(`typeof leaked`))
+	require.NoError(t, err)
+	assert.Equal(t, "undefined", result.Value.String())
+}
+
+func TestIsolationRunScript(t *testing.T) {
+	iso, err := NewIsolation(goja.New())
+	require.NoError(t, err)
+
+	// 顶层的 let、const 和 class 不会泄漏给下一次执行
+	for i := 1; i <= 2; i++ {
+		result, err := iso.RunScript(context.Background(), "lexical.js", fmt.Sprintf(`
+"use strict";
+let x = %d;
+const y = x + 1;
+class C {}
+function f() { return y; }
+f() // 脚本的值
+`, i))
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), result.Value.Export())
+	}
+	result, err := iso.RunScript(context.Background(), "check.js", `[typeof x, typeof y, typeof C, typeof f].join(" ")`)
+	require.NoError(t, err)
+	assert.Equal(t, "undefined undefined undefined undefined", result.Value.String())
+
+	// 异常的位置依然指向原来的行
+	_, err = iso.RunScript(context.Background(), "throw.js", "let z = 1;\nthrow new Error(\"boom\")")
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, 2, exception.Line)
+
+	// 以括号开头的语句同样可以编译
+	for src, want := range map[string]any{
+		`(function () { return 1 })()`:                                int64(1),
+		`"use strict"; (1 + 2) * 3`:                                   int64(9),
+		"'a'\n'use strict';\n/* ( */ (function () { return this })()": nil,
+		`"use strict"`: "use strict",
+	} {
+		result, err := iso.RunScript(context.Background(), "paren.js", src)
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, want, result.Value.Export(), src)
+		}
+	}
+
+	// Run 执行的脚本无法还原顶层的 let
+	_, err = iso.Run(context.Background(), runString(`let leaked = 1`))
+	require.NoError(t, err)
+	_, err = iso.Run(context.Background(), runString(`let leaked = 2`))
+	assert.ErrorContains(t, err, "Identifier 'leaked' has already been declared")
+}
+
+func TestIsolationRestoreFailed(t *testing.T) {
+	iso, err := NewIsolation(goja.New())
+	require.NoError(t, err)
+
+	_, err = iso.Run(context.Background(), runString(`
+Object.defineProperty(globalThis, "pinned", {value: 1});
+Object.freeze(Math);
+Math.abs = null;
+`))
+	assert.ErrorIs(t, err, ErrRestoreFailed)
+	assert.ErrorContains(t, err, "cannot restore pinned")
+}
+
+func TestPoolIsolate(t *testing.T) {
+	pool := newTestPool(PoolOptions{MaxIdle: 1, Isolate: true})
+
+	_, _, err := pool.Run(context.Background(), runString(leakScript))
+	require.NoError(t, err)
+	value, _, err := pool.Run(context.Background(), runString(checkLeakScript))
+	require.NoError(t, err)
+	assert.Equal(t, noLeak, value)
+	assert.Equal(t, PoolStats{Created: 1, Reused: 1, Idle: 1}, pool.Stats())
+
+	// 还原时不会执行脚本加在 Object.prototype 上的 getter
+	_, _, err = pool.Run(context.Background(), runString(`
+Object.defineProperty(Object.prototype, "get", {get: function () { for (;;) {} }, configurable: true});
+`))
+	require.NoError(t, err)
+	value, _, err = pool.Run(context.Background(), runString(`Object.prototype.hasOwnProperty("get")`))
+	require.NoError(t, err)
+	assert.Equal(t, false, value)
+	assert.Equal(t, PoolStats{Created: 1, Reused: 3, Idle: 1}, pool.Stats())
+
+	// 无法还原的 runtime 会被丢弃
+	_, _, err = pool.Run(context.Background(), runString(`Object.freeze(globalThis); leaked = 1`))
+	require.NoError(t, err)
+	assert.Equal(t, PoolStats{Created: 1, Reused: 4, Discarded: 1}, pool.Stats())
+
+	// goja 生成属性描述符时会执行继承的 setter ，还原超时的 runtime 会被丢弃
+	rt, err := pool.Get()
+	require.NoError(t, err)
+	_, err = rt.RunString(`Object.defineProperty(Object.prototype, "value", {set: function () { for (;;) {} }, configurable: true})`)
+	require.NoError(t, err)
+	pool.Put(rt, nil)
+	assert.Equal(t, PoolStats{Created: 2, Reused: 4, Discarded: 2}, pool.Stats())
+
+	// CompileIsolated 编译的脚本的顶层 let 也不会泄漏
+	for i := 0; i < 2; i++ {
+		value, _, err = pool.Run(context.Background(), func(runtime *goja.Runtime) (goja.Value, error) {
+			program, err := CompileIsolated("lexical.js", `let x = 1; x`)
+			if err != nil {
+				return nil, err
+			}
+			return runtime.RunProgram(program)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), value)
+	}
+
+	// 没有 Isolate 时全局变量会泄漏给下一次执行
+	pool = newTestPool(PoolOptions{MaxIdle: 1})
+	_, _, err = pool.Run(context.Background(), runString(`var counter = 42`))
+	require.NoError(t, err)
+	value, _, err = pool.Run(context.Background(), runString(`counter`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), value)
+}
+
+func TestIsolationSandbox(t *testing.T) {
+	sandbox := &Sandbox{}
+	pool := NewPool(PoolOptions{
+		Setup:   func(runtime *goja.Runtime) error { return sandbox.Apply(runtime) },
+		MaxIdle: 1,
+		Isolate: true,
+	})
+
+	_, _, err := pool.Run(context.Background(), runString(`var counter = 1; leaked = {}`))
+	require.NoError(t, err)
+	value, _, err := pool.Run(context.Background(), runString(`typeof counter + " " + typeof leaked`))
+	require.NoError(t, err)
+	assert.Equal(t, "undefined undefined", value)
+	assert.Equal(t, PoolStats{Created: 1, Reused: 1, Idle: 1}, pool.Stats())
+}
@@ -0,0 +1,343 @@
+package goja
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrCallStackExceeded is matched by runs exceeding Limits.MaxCallStackSize.
+	ErrCallStackExceeded = errors.New("goja: call stack size exceeded")
+	// ErrMemoryExceeded is matched by runs exceeding Limits.MaxAllocBytes.
+	ErrMemoryExceeded = errors.New("goja: memory limit exceeded")
+	// ErrHostCallsExceeded is matched by runs exceeding Limits.MaxHostCalls.
+	ErrHostCallsExceeded = errors.New("goja: host call limit exceeded")
+	// ErrOutputExceeded is matched by runs exceeding Limits.MaxOutputBytes.
+	ErrOutputExceeded = errors.New("goja: output size limit exceeded")
+	// ErrStepsExceeded is matched by runs exceeding Limits.MaxSteps.
+	ErrStepsExceeded = errors.New("goja: step limit exceeded")
+)
+
+// Limits bounds the resources of a run beyond its context deadline. Zero
+// fields are unlimited.
+type Limits struct {
+	// MaxCallStackSize is the maximum JS call depth. Without it a runaway
+	// recursion grows the Go stack until the process dies. As goja does not
+	// expose the depth set before, the runtime gets back its default, no
+	// limit, after the run.
+	MaxCallStackSize int
+	// MaxAllocBytes bounds the bytes allocated during the run. It is sampled
+	// from the process wide Go heap statistics every SampleInterval, so it
+	// is approximate and counts concurrent work too: it is a safety net
+	// against scripts exhausting memory, not an accounting.
+	MaxAllocBytes uint64
+	// SampleInterval is the heap sampling period, 10ms by default.
+	SampleInterval time.Duration
+	// MaxHostCalls bounds the calls to host functions wrapped by
+	// Limiter.Host.
+	MaxHostCalls int64
+	// MaxOutputBytes bounds the size of the value returned by the run,
+	// primitives by the length of their string and objects by their JSON
+	// encoding. Objects that cannot be encoded, such as cyclic ones, fail
+	// the run.
+	MaxOutputBytes int
+	// MaxSteps bounds the steps of the programs compiled with
+	// CompileMetered, a step being a loop iteration or a function call.
+	// Unlike the context deadline, it stops a script at the same point on
+	// every run.
+	MaxSteps int64
+}
+
+// LimitError reports a run stopped for exceeding one of its Limits. It
+// matches the corresponding Err* variable with errors.Is.
+type LimitError struct {
+	// Err is one of ErrCallStackExceeded, ErrMemoryExceeded,
+	// ErrHostCallsExceeded, ErrOutputExceeded or ErrStepsExceeded.
+	Err error
+	// Limit is the configured limit and Used the amount observed when the
+	// run was stopped, 0 when unknown.
+	Limit, Used int64
+	// cause is the error returned by the run, if any.
+	cause error
+}
+
+func (e *LimitError) Error() string {
+	if e.Used == 0 {
+		return fmt.Sprintf("%v (limit %d)", e.Err, e.Limit)
+	}
+	return fmt.Sprintf("%v (limit %d, used %d)", e.Err, e.Limit, e.Used)
+}
+
+func (e *LimitError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.cause}
+}
+
+// limitInterrupt is the value a Limiter interrupts the runtime with. It is
+// not an error itself, the error would otherwise unwrap to itself through
+// its cause.
+type limitInterrupt struct {
+	err *LimitError
+}
+
+func (li limitInterrupt) String() string {
+	return li.err.Error()
+}
+
+// Limiter enforces Limits on the runs of a runtime.
+type Limiter struct {
+	runtime *goja.Runtime
+	limits  Limits
+
+	mu        sync.Mutex // serializes Run
+	active    atomic.Bool
+	hostCalls atomic.Int64
+	steps     int64 // counted on the runtime goroutine only
+}
+
+// NewLimiter returns a Limiter enforcing limits on runtime.
+func NewLimiter(runtime *goja.Runtime, limits Limits) *Limiter {
+	if limits.SampleInterval <= 0 {
+		limits.SampleInterval = 10 * time.Millisecond
+	}
+	return &Limiter{runtime: runtime, limits: limits}
+}
+
+// Host wraps the Go function fn so that its calls during Run count against
+// MaxHostCalls, the result is meant for goja.Runtime.Set. Once the limit is
+// exceeded fn is not called anymore and the run is interrupted.
+func (l *Limiter) Host(fn any) any {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("goja: Limiter.Host: %T is not a function", fn))
+	}
+	t := v.Type()
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		if l.active.Load() && l.limits.MaxHostCalls > 0 {
+			if n := l.hostCalls.Add(1); n > l.limits.MaxHostCalls {
+				l.runtime.Interrupt(limitInterrupt{&LimitError{Err: ErrHostCallsExceeded, Limit: l.limits.MaxHostCalls, Used: n}})
+				results := make([]reflect.Value, t.NumOut())
+				for i := range results {
+					results[i] = reflect.Zero(t.Out(i))
+				}
+				return results
+			}
+		}
+		if t.IsVariadic() {
+			return v.CallSlice(args)
+		}
+		return v.Call(args)
+	}).Interface()
+}
+
+// Run is WrapRun with the limits enforced. Exceeding one returns a
+// *LimitError.
+func (l *Limiter) Run(ctx context.Context, f func(runtime *goja.Runtime) (goja.Value, error)) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limits.MaxCallStackSize > 0 {
+		l.runtime.SetMaxCallStackSize(l.limits.MaxCallStackSize)
+		defer l.runtime.SetMaxCallStackSize(math.MaxInt32)
+	}
+	l.hostCalls.Store(0)
+	l.steps = 0
+	if l.limits.MaxSteps > 0 {
+		hook, err := stepHookOf(l.runtime)
+		if err != nil {
+			return Result{}, err
+		}
+		hook.limiter = l
+		defer func() { hook.limiter = nil }()
+	}
+	l.active.Store(true)
+	defer l.active.Store(false)
+
+	stop := func() *LimitError { return nil }
+	if l.limits.MaxAllocBytes > 0 {
+		stop = l.sampleHeap()
+	}
+
+	var size int
+	result, err := WrapRun(ctx, l.runtime, func(runtime *goja.Runtime) (goja.Value, error) {
+		v, err := f(runtime)
+		if err != nil || l.limits.MaxOutputBytes <= 0 {
+			return v, err
+		}
+		// measured within the run, so that ctx interrupts the getters of v
+		size, err = outputSize(runtime, v)
+		return v, err
+	})
+	sampled := stop()
+	if err != nil {
+		return result, l.limitError(err)
+	}
+	// a limit hit as the script ended leaves the interrupt flag set
+	if sampled != nil {
+		l.runtime.ClearInterrupt()
+		result.Value = nil
+		return result, sampled
+	}
+	if n := l.hostCalls.Load(); l.limits.MaxHostCalls > 0 && n > l.limits.MaxHostCalls {
+		l.runtime.ClearInterrupt()
+		result.Value = nil
+		return result, &LimitError{Err: ErrHostCallsExceeded, Limit: l.limits.MaxHostCalls, Used: n}
+	}
+	if l.limits.MaxSteps > 0 && l.steps > l.limits.MaxSteps {
+		l.runtime.ClearInterrupt()
+		result.Value = nil
+		return result, &LimitError{Err: ErrStepsExceeded, Limit: l.limits.MaxSteps, Used: l.steps}
+	}
+	if l.limits.MaxOutputBytes > 0 && size > l.limits.MaxOutputBytes {
+		result.Value = nil
+		return result, &LimitError{Err: ErrOutputExceeded, Limit: int64(l.limits.MaxOutputBytes), Used: int64(size)}
+	}
+	return result, nil
+}
+
+// Steps returns the steps counted by the last run, 0 without MaxSteps.
+func (l *Limiter) Steps() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.steps
+}
+
+// stepHook is the step counter of a runtime, shared by its Limiters. It is
+// a non writable and non configurable global, scripts cannot replace it,
+// and the Limiter running sets itself as the current one.
+type stepHook struct {
+	limiter *Limiter // nil out of Run
+}
+
+// stepHookKey holds the stepHook on the step function, as a Go value the
+// scripts cannot alter.
+var stepHookKey = goja.NewSymbol("stepHook")
+
+// stepHookOf returns the stepHook of runtime, defining it on first use.
+func stepHookOf(runtime *goja.Runtime) (*stepHook, error) {
+	if fn, ok := runtime.GlobalObject().Get(stepFunc).(*goja.Object); ok {
+		if v := fn.GetSymbol(stepHookKey); v != nil {
+			if hook, ok := v.Export().(*stepHook); ok {
+				return hook, nil
+			}
+		}
+	}
+	hook := &stepHook{}
+	fn := runtime.ToValue(hook.step).(*goja.Object)
+	if err := fn.DefineDataPropertySymbol(stepHookKey, runtime.ToValue(hook), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+		return nil, errors.Wrap(err, "failed to set the step counter")
+	}
+	if err := runtime.GlobalObject().DefineDataProperty(stepFunc, fn, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+		return nil, errors.Wrap(err, "failed to set the step counter")
+	}
+	return hook, nil
+}
+
+func (h *stepHook) step(goja.FunctionCall) goja.Value {
+	if h.limiter != nil {
+		h.limiter.step()
+	}
+	return goja.Undefined()
+}
+
+// step is called by the metered programs. The interrupt takes effect as it
+// returns, before the next instruction of the script.
+func (l *Limiter) step() {
+	if !l.active.Load() {
+		return
+	}
+	l.steps++
+	if l.steps > l.limits.MaxSteps {
+		l.runtime.Interrupt(limitInterrupt{&LimitError{Err: ErrStepsExceeded, Limit: l.limits.MaxSteps, Used: l.steps}})
+	}
+}
+
+// limitError turns the errors of exceeded limits into a *LimitError.
+func (l *Limiter) limitError(err error) error {
+	var interrupted *InterruptedError
+	if errors.As(err, &interrupted) {
+		if li, ok := interrupted.Value.(limitInterrupt); ok {
+			li.err.cause = err
+			return li.err
+		}
+	}
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		return &LimitError{Err: ErrCallStackExceeded, Limit: int64(l.limits.MaxCallStackSize), cause: err}
+	}
+	return err
+}
+
+// sampleHeap interrupts the runtime once the bytes allocated since the call
+// exceed MaxAllocBytes, until stop is called. stop returns the error the
+// runtime was interrupted with, if any.
+func (l *Limiter) sampleHeap() (stop func() *LimitError) {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	read := func() uint64 {
+		metrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+	start := read()
+
+	done := make(chan struct{})
+	var (
+		wg       sync.WaitGroup
+		exceeded *LimitError
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(l.limits.SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if used := read() - start; used > l.limits.MaxAllocBytes {
+					exceeded = &LimitError{Err: ErrMemoryExceeded, Limit: int64(l.limits.MaxAllocBytes), Used: int64(used)}
+					l.runtime.Interrupt(limitInterrupt{exceeded})
+					return
+				}
+			}
+		}
+	}()
+	return func() *LimitError {
+		close(done)
+		wg.Wait()
+		return exceeded
+	}
+}
+
+// outputSize returns the length of the primitive v, or the size of the JSON
+// encoding of the object v. Encoding runs its getters and toJSON methods,
+// and fails like JSON.stringify for cycles and BigInts.
+func outputSize(runtime *goja.Runtime, v goja.Value) (int, error) {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+			return 0, nil
+		}
+		return len(v.String()), nil
+	}
+	var data []byte
+	var err error
+	if gerr := guard(runtime, func() {
+		data, err = json.Marshal(obj)
+	}); gerr != nil {
+		return 0, gerr
+	}
+	return len(data), err
+}
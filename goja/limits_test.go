@@ -0,0 +1,128 @@
+package goja
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterCallStack(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxCallStackSize: 100})
+
+	_, err := limiter.Run(context.Background(), runString(`function f(n) { return f(n + 1); } f(0);`))
+	assert.ErrorIs(t, err, ErrCallStackExceeded)
+	assert.ErrorAs(t, err, new(*goja.StackOverflowError))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, int64(100), limitErr.Limit)
+
+	// 限制之内的递归正常执行
+	result, err := limiter.Run(context.Background(), runString(`function g(n) { return n == 0 ? 0 : 1 + g(n - 1); } g(50);`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), result.Value.Export())
+
+	// 限制只在 Run 期间生效
+	v, err := vm.RunString(`g(200)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), v.Export())
+}
+
+func TestLimiterMemory(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxAllocBytes: 32 << 20, SampleInterval: time.Millisecond})
+
+	// 不会超时，但是会持续申请内存
+	start := time.Now()
+	_, err := limiter.Run(context.Background(), runString(`
+var chunks = [];
+for (;;) {
+	chunks.push(new Array(1024).fill("x"));
+}
+`))
+	assert.ErrorIs(t, err, ErrMemoryExceeded)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Greater(t, limitErr.Used, int64(32<<20))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 同一个 runtime 依然可用
+	result, err := limiter.Run(context.Background(), runString(`1 + 2`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Value.Export())
+}
+
+func TestLimiterHostCalls(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxHostCalls: 3})
+	calls := 0
+	require.NoError(t, vm.Set("record", limiter.Host(func(v string) string {
+		calls++
+		return v
+	})))
+
+	_, err := limiter.Run(context.Background(), runString(`for (var i = 0; i < 10; i++) { record("x"); }`))
+	assert.ErrorIs(t, err, ErrHostCallsExceeded)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, int64(4), limitErr.Used)
+	// 超出限制的调用不会真正执行
+	assert.Equal(t, 3, calls)
+
+	// 计数在每次执行时重置，且最后一次调用超限也能被发现
+	_, err = limiter.Run(context.Background(), runString(`record("a"); record("b"); record("c"); record("d")`))
+	assert.ErrorIs(t, err, ErrHostCallsExceeded)
+
+	result, err := limiter.Run(context.Background(), runString(`record("a") + record("b")`))
+	require.NoError(t, err)
+	assert.Equal(t, "ab", result.Value.String())
+}
+
+func TestLimiterOutput(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxOutputBytes: 16})
+
+	_, err := limiter.Run(context.Background(), runString(`"x".repeat(17)`))
+	assert.ErrorIs(t, err, ErrOutputExceeded)
+
+	_, err = limiter.Run(context.Background(), runString(`({items: [1, 2, 3, 4, 5]})`))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ErrOutputExceeded, limitErr.Err)
+	assert.Equal(t, int64(len(`{"items":[1,2,3,4,5]}`)), limitErr.Used)
+
+	result, err := limiter.Run(context.Background(), runString(`({ok: true})`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"ok": true}, result.Value.Export())
+
+	// 无法编码的值返回 JSON.stringify 的错误，而不是被低估
+	_, err = limiter.Run(context.Background(), runString(`var o = {}; o.self = o; o`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "TypeError", exception.Name)
+
+	// 计算大小时执行的 getter 依然会被 ctx 中断
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err = limiter.Run(ctx, runString(`({get x() { for (;;) {} }})`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+}
+
+func TestLimiterContext(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxCallStackSize: 100, MaxAllocBytes: 1 << 30})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// 其他错误原样返回
+	_, err := limiter.Run(ctx, runString(loopScript))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorAs(t, err, new(*InterruptedError))
+	assert.NotErrorAs(t, err, new(*LimitError))
+}
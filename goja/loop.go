@@ -0,0 +1,364 @@
+package goja
+
+import (
+	"container/heap"
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// ErrPromisePending is returned by Loop.Run when the script returned a
+// promise that can never settle since nothing is pending anymore.
+var ErrPromisePending = errors.New("goja: promise still pending with nothing left to run")
+
+var promiseType = reflect.TypeFor[*goja.Promise]()
+
+// Loop is an event loop for a runtime. It provides setTimeout,
+// clearTimeout, setInterval and clearInterval, and lets host functions
+// return promises settled from other goroutines with Go. Promise jobs run
+// as soon as the script or callback scheduling them returns.
+//
+// Timers and asynchronous operations only progress during Run.
+type Loop struct {
+	runtime *goja.Runtime
+	clock   Clock
+
+	mu         sync.Mutex
+	generation uint64 // bumped by every Run, drops the work of the previous
+	jobs       []func() error
+	pending    int // operations started by Go not settled yet
+	wakeup     chan struct{}
+
+	// used on the loop only
+	ctx    context.Context
+	timers timerHeap
+	byID   map[int64]*loopTimer
+	nextID int64
+	seq    uint64
+}
+
+// NewLoop returns a Loop for runtime and installs the timer functions.
+func NewLoop(runtime *goja.Runtime) (*Loop, error) {
+	l := &Loop{
+		runtime: runtime,
+		clock:   realClock{},
+		wakeup:  make(chan struct{}, 1),
+		ctx:     context.Background(),
+		byID:    map[int64]*loopTimer{},
+	}
+	for name, fn := range map[string]func(goja.FunctionCall) goja.Value{
+		"setTimeout":    func(call goja.FunctionCall) goja.Value { return l.setTimer(call, false) },
+		"setInterval":   func(call goja.FunctionCall) goja.Value { return l.setTimer(call, true) },
+		"clearTimeout":  l.clearTimer,
+		"clearInterval": l.clearTimer,
+	} {
+		if err := runtime.Set(name, fn); err != nil {
+			return nil, errors.Wrapf(err, "failed to set %s", name)
+		}
+	}
+	return l, nil
+}
+
+// SetClock makes the timers follow clock instead of the system time, a
+// FakeClock in tests. It must not be called during Run.
+func (l *Loop) SetClock(clock Clock) {
+	l.clock = clock
+}
+
+// Go runs fn in a new goroutine and returns a promise settled with its
+// result, a non nil error rejects it with a GoError that unwraps to the
+// error. It must be called on the loop, typically from a host function
+// during Run. The context passed to fn is canceled when Run returns.
+func (l *Loop) Go(fn func(ctx context.Context) (any, error)) *goja.Promise {
+	promise, resolve, reject := l.runtime.NewPromise()
+
+	l.mu.Lock()
+	l.pending++
+	generation := l.generation
+	l.mu.Unlock()
+
+	ctx := l.ctx
+	go func() {
+		value, err := fn(ctx)
+		l.enqueue(generation, true, func() error {
+			if err != nil {
+				reject(l.runtime.NewGoError(err))
+			} else {
+				resolve(value)
+			}
+			return nil
+		})
+	}()
+	return promise
+}
+
+// RunOnLoop schedules fn to run on the loop of the current Run, it may be
+// called from any goroutine. Unlike Go it does not keep the run going, fn is
+// dropped when the run ends first.
+func (l *Loop) RunOnLoop(fn func(runtime *goja.Runtime)) {
+	l.mu.Lock()
+	generation := l.generation
+	l.mu.Unlock()
+	l.enqueue(generation, false, func() error {
+		fn(l.runtime)
+		return nil
+	})
+}
+
+func (l *Loop) enqueue(generation uint64, settles bool, job func() error) {
+	l.mu.Lock()
+	if generation != l.generation {
+		l.mu.Unlock()
+		return // the run it belongs to is over
+	}
+	if settles {
+		l.pending--
+	}
+	l.jobs = append(l.jobs, job)
+	l.mu.Unlock()
+
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs f with WrapRun, then drives timers and asynchronous operations
+// until none is left. When f returns a promise, its settled value is the
+// value of the result and its rejection is returned as an *ExceptionError.
+//
+// Canceling ctx interrupts the running JS, drops the pending timers and
+// cancels the context of the operations started by Go. Exceptions thrown by
+// timer callbacks end the run.
+func (l *Loop) Run(ctx context.Context, f func(runtime *goja.Runtime) (goja.Value, error)) (result Result, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	l.ctx = ctx
+	defer l.reset()
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	result, err = WrapRun(ctx, l.runtime, f)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return result, &InterruptedError{Value: context.Cause(ctx), Context: true}
+		}
+		for _, job := range l.takeJobs() {
+			if err := l.step(ctx, &result, job); err != nil {
+				return result, err
+			}
+		}
+
+		if len(l.timers) == 0 {
+			if !l.busy() {
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-l.wakeup:
+			}
+			continue
+		}
+
+		wait := l.timers[0].due.Sub(l.clock.Now())
+		if wait <= 0 {
+			if err := l.step(ctx, &result, l.fire); err != nil {
+				return result, err
+			}
+			continue
+		}
+		fired, stop := l.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+		case <-l.wakeup:
+		case <-fired:
+		}
+		stop()
+	}
+
+	// told without exporting, which would run the getters of other objects
+	if result.Value == nil || result.Value.ExportType() != promiseType {
+		return result, nil
+	}
+	promise := result.Value.Export().(*goja.Promise)
+	switch promise.State() {
+	case goja.PromiseStateFulfilled:
+		result.Value = promise.Result()
+		return result, nil
+	case goja.PromiseStateRejected:
+		result.Value = nil
+		// the reason is described under the interrupt as well
+		var rejected *ExceptionError
+		if err := l.step(ctx, &result, func() error {
+			rejected = rejectionError(l.runtime, promise.Result())
+			return nil
+		}); err != nil {
+			return result, err
+		}
+		return result, rejected
+	default:
+		result.Value = nil
+		return result, ErrPromisePending
+	}
+}
+
+// step runs job under WrapRun so that it is interrupted with ctx.
+func (l *Loop) step(ctx context.Context, result *Result, job func() error) error {
+	if ctx.Err() != nil {
+		return &InterruptedError{Value: context.Cause(ctx), Context: true}
+	}
+	r, err := WrapRun(ctx, l.runtime, func(*goja.Runtime) (goja.Value, error) {
+		return nil, job()
+	})
+	result.Interrupted = result.Interrupted || r.Interrupted
+	return err
+}
+
+func (l *Loop) takeJobs() []func() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	jobs := l.jobs
+	l.jobs = nil
+	return jobs
+}
+
+func (l *Loop) busy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending > 0 || len(l.jobs) > 0
+}
+
+// reset drops everything left by a run.
+func (l *Loop) reset() {
+	l.mu.Lock()
+	l.generation++
+	l.jobs = nil
+	l.pending = 0
+	l.mu.Unlock()
+
+	l.ctx = context.Background()
+	l.timers = nil
+	clear(l.byID)
+}
+
+type loopTimer struct {
+	id       int64
+	due      time.Time
+	seq      uint64 // orders timers due at the same time
+	interval time.Duration
+	repeat   bool
+	fn       goja.Callable
+	args     []goja.Value
+	index    int
+}
+
+func (l *Loop) setTimer(call goja.FunctionCall, repeat bool) goja.Value {
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(l.runtime.NewTypeError("callback must be a function"))
+	}
+	var delay time.Duration
+	if ms := call.Argument(1).ToFloat(); ms > 0 {
+		delay = time.Duration(ms * float64(time.Millisecond))
+	}
+	var args []goja.Value
+	if len(call.Arguments) > 2 {
+		args = append(args, call.Arguments[2:]...)
+	}
+
+	l.nextID++
+	t := &loopTimer{
+		id:       l.nextID,
+		interval: delay,
+		repeat:   repeat,
+		fn:       fn,
+		args:     args,
+	}
+	l.schedule(t)
+	l.byID[t.id] = t
+	return l.runtime.ToValue(t.id)
+}
+
+func (l *Loop) schedule(t *loopTimer) {
+	l.seq++
+	t.due = l.clock.Now().Add(t.interval)
+	t.seq = l.seq
+	heap.Push(&l.timers, t)
+}
+
+func (l *Loop) clearTimer(call goja.FunctionCall) goja.Value {
+	id := call.Argument(0).ToInteger()
+	if t, ok := l.byID[id]; ok {
+		delete(l.byID, id)
+		heap.Remove(&l.timers, t.index)
+	}
+	return goja.Undefined()
+}
+
+// fire runs the earliest timer.
+func (l *Loop) fire() error {
+	t := heap.Pop(&l.timers).(*loopTimer)
+	if t.repeat {
+		l.schedule(t)
+	} else {
+		delete(l.byID, t.id)
+	}
+	_, err := t.fn(goja.Undefined(), t.args...)
+	return err
+}
+
+type timerHeap []*loopTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if !h[i].due.Equal(h[j].due) {
+		return h[i].due.Before(h[j].due)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*loopTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// rejectionError describes the reason a promise was rejected with.
+func rejectionError(runtime *goja.Runtime, reason goja.Value) *ExceptionError {
+	e := &ExceptionError{}
+	e.describe(runtime, reason)
+	if obj, ok := reason.(*goja.Object); ok {
+		// a GoError keeps the Go error in its value property
+		var v goja.Value
+		if guard(runtime, func() { v = obj.Get("value") }) == nil && v != nil &&
+			v.ExportType() != nil && v.ExportType().Implements(errorType) {
+			e.cause = v.Export().(error)
+		}
+	}
+	return e
+}
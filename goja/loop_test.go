@@ -0,0 +1,191 @@
+package goja
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoop(t *testing.T) (*goja.Runtime, *Loop) {
+	vm := goja.New()
+	loop, err := NewLoop(vm)
+	require.NoError(t, err)
+	return vm, loop
+}
+
+func TestLoopTimers(t *testing.T) {
+	_, loop := newTestLoop(t)
+	result, err := loop.Run(context.Background(), runString(`
+var log = [];
+setTimeout(function (v) { log.push(v); }, 20, "b");
+setTimeout(function () { log.push("a"); }, 10);
+var cleared = setTimeout(function () { log.push("never"); }, 5);
+clearTimeout(cleared);
+var n = 0;
+var id = setInterval(function () {
+	log.push("tick" + (++n));
+	if (n == 3) {
+		clearInterval(id);
+	}
+}, 1);
+Promise.resolve().then(function () { log.push("micro"); });
+log;
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"micro", "tick1", "tick2", "tick3", "a", "b"}, result.Value.Export())
+}
+
+func TestLoopAsyncHostFunction(t *testing.T) {
+	vm, loop := newTestLoop(t)
+	require.NoError(t, vm.Set("fetchUser", func(id string) *goja.Promise {
+		return loop.Go(func(ctx context.Context) (any, error) {
+			time.Sleep(5 * time.Millisecond)
+			if id == "" {
+				return nil, errNotFound
+			}
+			return map[string]any{"id": id, "name": "user " + id}, nil
+		})
+	}))
+
+	result, err := loop.Run(context.Background(), runString(`
+(async function () {
+	var [a, b] = await Promise.all([fetchUser("1"), fetchUser("2")]);
+	return a.name + ", " + b.name;
+})()
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "user 1, user 2", result.Value.String())
+
+	// 在 JS 内 catch Go 的错误
+	result, err = loop.Run(context.Background(), runString(`
+(async function () {
+	try {
+		await fetchUser("");
+	} catch (e) {
+		return "caught: " + e.message;
+	}
+})()
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "caught: not found", result.Value.String())
+
+	// 未处理的 rejection 作为错误返回，并能取回原始的 Go 错误
+	_, err = loop.Run(context.Background(), runString(`(async function () { await fetchUser(""); })()`))
+	assert.ErrorIs(t, err, errNotFound)
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "GoError", exception.Name)
+	assert.Equal(t, "not found", exception.Message)
+
+	_, err = loop.Run(context.Background(), runString(`Promise.reject(new RangeError("bad"))`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "RangeError", exception.Name)
+	assert.Equal(t, "bad", exception.Message)
+}
+
+func TestLoopRunOnLoop(t *testing.T) {
+	vm, loop := newTestLoop(t)
+	require.NoError(t, vm.Set("later", func(v string) *goja.Promise {
+		promise, resolve, _ := vm.NewPromise()
+		go loop.RunOnLoop(func(*goja.Runtime) { resolve(v + "!") })
+		return promise
+	}))
+	// RunOnLoop 不会让 loop 保持等待，这里用定时器让其等待
+	result, err := loop.Run(context.Background(), runString(`
+var timer = setTimeout(function () {}, 1000);
+later("hi").then(function (v) {
+	clearTimeout(timer);
+	return v;
+});
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "hi!", result.Value.String())
+}
+
+func TestLoopCancel(t *testing.T) {
+	vm, loop := newTestLoop(t)
+	canceled := make(chan error, 1)
+	require.NoError(t, vm.Set("wait", func() *goja.Promise {
+		return loop.Go(func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			canceled <- ctx.Err()
+			return nil, ctx.Err()
+		})
+	}))
+
+	// 等待 Go 的异步操作时取消
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := loop.Run(ctx, runString(`wait()`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorAs(t, err, new(*InterruptedError))
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("the context of the async operation was not canceled")
+	}
+
+	// 等待定时器时取消，定时器不会遗留到下一次执行
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = loop.Run(ctx, runString(`var fired = false; setTimeout(function () { fired = true; }, 50);`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	result, err := loop.Run(context.Background(), runString(`setTimeout(function () {}, 60); fired`))
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Value.Export())
+
+	// 定时器回调里的死循环会被中断
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err = loop.Run(ctx, runString(`setTimeout(function () { for (;;) {} }, 1);`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+}
+
+func TestLoopErrors(t *testing.T) {
+	_, loop := newTestLoop(t)
+
+	_, err := loop.Run(context.Background(), runString(`setTimeout(function () { throw new Error("in timer"); }, 1);`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "in timer", exception.Message)
+
+	_, err = loop.Run(context.Background(), runString(`new Promise(function () {})`))
+	assert.ErrorIs(t, err, ErrPromisePending)
+
+	_, err = loop.Run(context.Background(), runString(`setTimeout("not a function")`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "TypeError", exception.Name)
+	assert.False(t, errors.Is(err, ErrPromisePending))
+}
+
+func TestLoopResultGetters(t *testing.T) {
+	_, loop := newTestLoop(t)
+
+	// 判断结果是否为 promise 时不会执行其 getter
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := loop.Run(ctx, runString(`({get then() { for (;;) {} }, get x() { for (;;) {} }})`))
+	require.NoError(t, err)
+	assert.False(t, result.Interrupted)
+
+	// 拒绝原因的 getter 依然会被 ctx 中断
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err = loop.Run(ctx, runString(`Promise.reject({get message() { for (;;) {} }})`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "[object Object]", exception.Message)
+	assert.True(t, result.Interrupted)
+
+	// getter 抛出异常时不会 panic
+	_, err = loop.Run(context.Background(), runString(`Promise.reject({name: "Custom", get message() { throw 1 }, get value() { throw 2 }})`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "Custom", exception.Name)
+}
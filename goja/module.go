@@ -0,0 +1,159 @@
+package goja
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrModuleNotFound is matched by the errors of require for modules
+	// missing from the filesystem.
+	ErrModuleNotFound = errors.New("goja: module not found")
+	// ErrModuleOutsideRoot is matched by the errors of require for paths
+	// leaving the root of the filesystem.
+	ErrModuleOutsideRoot = errors.New("goja: module path outside of the root")
+)
+
+// ModuleLoader provides CommonJS modules to a runtime from a filesystem, an
+// embed.FS, an fstest.MapFS or an os.DirFS for instance. It installs a global
+// require function. goja does not support ES modules, so import statements
+// are not available.
+//
+// Relative specifiers ("./x", "../x") resolve against the requiring module,
+// the others against the root of the filesystem, and cannot leave it. A
+// specifier names a file as is, with a .js or .json extension, or a directory
+// with an index.js or index.json. JSON modules export their parsed content.
+//
+// Modules are evaluated once per loader and their exports shared by the later
+// requires, cyclic requires see the exports of the module being loaded so
+// far, as in Node.js. Sources are compiled through DefaultProgramCache.
+type ModuleLoader struct {
+	runtime *goja.Runtime
+	fsys    fs.FS
+	modules map[string]*goja.Object // by path, the module objects
+}
+
+// NewModuleLoader returns a ModuleLoader for runtime reading fsys and sets
+// the global require function.
+func NewModuleLoader(runtime *goja.Runtime, fsys fs.FS) (*ModuleLoader, error) {
+	m := &ModuleLoader{
+		runtime: runtime,
+		fsys:    fsys,
+		modules: map[string]*goja.Object{},
+	}
+	if err := runtime.Set("require", m.requireFunc(".")); err != nil {
+		return nil, errors.Wrap(err, "failed to set require")
+	}
+	return m, nil
+}
+
+// Require returns the exports of the module specified from the root of the
+// filesystem. It runs JS, call it within WrapRun.
+func (m *ModuleLoader) Require(specifier string) (goja.Value, error) {
+	require, _ := goja.AssertFunction(m.runtime.ToValue(m.requireFunc(".")))
+	return require(goja.Undefined(), m.runtime.ToValue(specifier))
+}
+
+func (m *ModuleLoader) requireFunc(dir string) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		specifier, ok := call.Argument(0).Export().(string)
+		if !ok || specifier == "" {
+			panic(m.runtime.NewTypeError("require: the module specifier must be a non empty string"))
+		}
+		return m.require(dir, specifier)
+	}
+}
+
+// require loads the module specified from dir and returns its exports, it
+// throws on failure.
+func (m *ModuleLoader) require(dir, specifier string) goja.Value {
+	name, err := m.resolve(dir, specifier)
+	if err != nil {
+		panic(m.runtime.NewGoError(err))
+	}
+	if module, ok := m.modules[name]; ok {
+		return module.Get("exports")
+	}
+
+	data, err := fs.ReadFile(m.fsys, name)
+	if err != nil {
+		panic(m.runtime.NewGoError(errors.Wrapf(err, "failed to read module %q", name)))
+	}
+
+	module := m.runtime.NewObject()
+	exports := m.runtime.NewObject()
+	_ = module.Set("id", name)
+	_ = module.Set("exports", exports)
+	m.modules[name] = module
+
+	ok := false
+	defer func() {
+		if !ok {
+			// a failed module is evaluated again by the next require
+			delete(m.modules, name)
+		}
+	}()
+
+	if path.Ext(name) == ".json" {
+		_ = module.Set("exports", m.parseJSON(name, data))
+		ok = true
+		return module.Get("exports")
+	}
+
+	// keep the first line of the source on the first line for the positions
+	program, err := DefaultProgramCache.Compile(name, "(function (exports, require, module, __filename, __dirname) {"+string(data)+"\n})")
+	if err != nil {
+		panic(m.runtime.NewGoError(errors.Wrapf(err, "failed to compile module %q", name)))
+	}
+	wrapper, err := m.runtime.RunProgram(program)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(wrapper)
+	if _, err := fn(exports, exports, m.runtime.ToValue(m.requireFunc(path.Dir(name))), module, m.runtime.ToValue(name), m.runtime.ToValue(path.Dir(name))); err != nil {
+		panic(err)
+	}
+	ok = true
+	return module.Get("exports")
+}
+
+// resolve returns the path of the module specified from dir.
+func (m *ModuleLoader) resolve(dir, specifier string) (string, error) {
+	var base string
+	if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") {
+		base = path.Join(dir, specifier)
+	} else {
+		base = path.Clean(strings.TrimPrefix(specifier, "/"))
+	}
+	if base == ".." || strings.HasPrefix(base, "../") {
+		return "", errors.Wrapf(ErrModuleOutsideRoot, "cannot require %q from %q", specifier, dir)
+	}
+
+	for _, name := range []string{base, base + ".js", base + ".json", path.Join(base, "index.js"), path.Join(base, "index.json")} {
+		if !fs.ValidPath(name) {
+			continue
+		}
+		info, err := fs.Stat(m.fsys, name)
+		if err == nil && !info.IsDir() {
+			return name, nil
+		}
+	}
+	return "", errors.Wrapf(ErrModuleNotFound, "cannot find module %q from %q", specifier, dir)
+}
+
+func (m *ModuleLoader) parseJSON(name string, data []byte) goja.Value {
+	parse, _ := goja.AssertFunction(m.runtime.Get("JSON").ToObject(m.runtime).Get("parse"))
+	v, err := parse(goja.Undefined(), m.runtime.ToValue(string(data)))
+	if err != nil {
+		var exception *goja.Exception
+		if errors.As(err, &exception) {
+			panic(m.runtime.NewGoError(errors.Wrapf(err, "failed to parse module %q", name)))
+		}
+		panic(err)
+	}
+	return v
+}
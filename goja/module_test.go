@@ -0,0 +1,149 @@
+package goja
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModules = fstest.MapFS{
+	"lib/math.js": {Data: []byte(`
+exports.add = function (a, b) { return a + b; };
+exports.square = function (a) { return require("./internal/mul").mul(a, a); };
+`)},
+	"lib/internal/mul.js": {Data: []byte(`module.exports = { mul: function (a, b) { return a * b; } };`)},
+	"lib/counter.js": {Data: []byte(`
+globalThis.counterLoads = (globalThis.counterLoads || 0) + 1;
+var n = 0;
+module.exports = function () { return ++n; };
+`)},
+	"lib/strings/index.js": {Data: []byte(`exports.upper = function (s) { return s.toUpperCase(); }; exports.file = __filename; exports.dir = __dirname;`)},
+	"config.json":          {Data: []byte(`{"name": "app", "tags": ["a", "b"]}`)},
+	"cycle/a.js": {Data: []byte(`
+exports.loaded = false;
+var b = require("./b");
+exports.loaded = true;
+exports.sawB = b.loaded;
+`)},
+	"cycle/b.js": {Data: []byte(`
+var a = require("./a");
+exports.sawA = a.loaded;
+exports.loaded = true;
+`)},
+	"broken/throws.js": {Data: []byte(`throw new Error("broken module");`)},
+	"broken/syntax.js": {Data: []byte(`var = 1;`)},
+	"broken/bad.json":  {Data: []byte(`{"name": }`)},
+	"escape.js":        {Data: []byte(`module.exports = require("../secret");`)},
+}
+
+func newTestModuleLoader(t *testing.T) (*goja.Runtime, *ModuleLoader) {
+	vm := goja.New()
+	loader, err := NewModuleLoader(vm, testModules)
+	require.NoError(t, err)
+	return vm, loader
+}
+
+func TestModuleLoader(t *testing.T) {
+	vm, _ := newTestModuleLoader(t)
+
+	result, err := WrapRun(context.Background(), vm, runString(`
+var math = require("lib/math");
+var strings = require("./lib/strings");
+var config = require("/config.json");
+[
+	math.add(1, 2),
+	math.square(4),
+	strings.upper("x"),
+	strings.file,
+	strings.dir,
+	config.name,
+	config.tags.length,
+]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(3), int64(16), "X", "lib/strings/index.js", "lib/strings", "app", int64(2)}, result.Value.Export())
+}
+
+func TestModuleLoaderCache(t *testing.T) {
+	vm, loader := newTestModuleLoader(t)
+
+	// 模块只执行一次，后续 require 共享同一个实例
+	result, err := WrapRun(context.Background(), vm, runString(`
+var c1 = require("lib/counter");
+var c2 = require("./lib/counter.js");
+c1(); c2();
+[c1 === c2, c1(), counterLoads]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{true, int64(3), int64(1)}, result.Value.Export())
+
+	// 之后的执行依然使用缓存的实例
+	result, err = WrapRun(context.Background(), vm, func(*goja.Runtime) (goja.Value, error) {
+		return loader.Require("lib/counter")
+	})
+	require.NoError(t, err)
+	fn, ok := goja.AssertFunction(result.Value)
+	require.True(t, ok)
+	v, err := fn(goja.Undefined())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), v.Export())
+
+	// 其他 runtime 的 loader 有自己的实例
+	other, _ := newTestModuleLoader(t)
+	result, err = WrapRun(context.Background(), other, runString(`require("lib/counter")()`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Value.Export())
+}
+
+func TestModuleLoaderCycle(t *testing.T) {
+	vm, _ := newTestModuleLoader(t)
+	result, err := WrapRun(context.Background(), vm, runString(`
+var a = require("cycle/a");
+var b = require("cycle/b");
+[a.loaded, a.sawB, b.sawA]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{true, true, false}, result.Value.Export())
+}
+
+func TestModuleLoaderErrors(t *testing.T) {
+	vm, loader := newTestModuleLoader(t)
+
+	_, err := WrapRun(context.Background(), vm, runString(`require("lib/missing")`))
+	assert.ErrorIs(t, err, ErrModuleNotFound)
+	assert.ErrorAs(t, err, new(*ExceptionError))
+
+	// 不能访问根目录之外的路径
+	for _, src := range []string{`require("../secret")`, `require("lib/../../secret")`, `require("escape")`} {
+		_, err = WrapRun(context.Background(), vm, runString(src))
+		assert.ErrorIs(t, err, ErrModuleOutsideRoot, src)
+	}
+
+	// 模块抛出的异常保留其原始位置
+	_, err = WrapRun(context.Background(), vm, runString(`require("broken/throws")`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "broken module", exception.Message)
+	assert.Equal(t, "broken/throws.js", exception.File)
+	assert.Equal(t, 1, exception.Line)
+
+	_, err = WrapRun(context.Background(), vm, runString(`require("broken/syntax")`))
+	assert.ErrorAs(t, err, new(*goja.CompilerSyntaxError))
+
+	_, err = WrapRun(context.Background(), vm, runString(`require("broken/bad.json")`))
+	assert.ErrorContains(t, err, `failed to parse module "broken/bad.json"`)
+
+	// 失败的模块不会被缓存
+	_, err = WrapRun(context.Background(), vm, func(*goja.Runtime) (goja.Value, error) {
+		return loader.Require("broken/throws")
+	})
+	assert.ErrorContains(t, err, "broken module")
+
+	_, err = WrapRun(context.Background(), vm, runString(`require(1)`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "TypeError", exception.Name)
+}
@@ -0,0 +1,267 @@
+package polyfill
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// StructuredClone sets structuredClone. It deep copies primitives, plain
+// objects and arrays, Date, RegExp, Map, Set, ArrayBuffer, typed arrays,
+// DataView, errors and boxed primitives, keeping cycles and shared
+// references. The instances of classes are copied as plain objects, their own
+// enumerable properties only. Functions, symbols and other objects throw a
+// DataCloneError.
+//
+// The types are told by their internal slots, with the builtins captured
+// when StructuredClone is called, so that scripts cannot pass an object off
+// as another type or break the clone by replacing the builtins.
+func StructuredClone(runtime *goja.Runtime) error {
+	v, err := runtime.RunString(cloneScript)
+	if err != nil {
+		return errors.Wrap(err, "failed to set up structuredClone")
+	}
+	helpers := v.ToObject(runtime)
+	var h cloneHelpers
+	for name, fn := range map[string]*goja.Callable{
+		"type": &h.typeOf, "copy": &h.copy, "entries": &h.entries, "collection": &h.collection,
+		"add": &h.add, "view": &h.view, "newView": &h.newView, "newError": &h.newError,
+		"newArray": &h.newArray, "newObject": &h.newObject,
+	} {
+		var ok bool
+		if *fn, ok = goja.AssertFunction(helpers.Get(name)); !ok {
+			return errors.Errorf("failed to set up structuredClone: %s is not a function", name)
+		}
+	}
+	return setGlobals(runtime, map[string]any{
+		"structuredClone": func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) == 0 {
+				panic(runtime.NewTypeError("structuredClone: 1 argument required, but only 0 present"))
+			}
+			c := &cloner{runtime: runtime, helpers: &h, seen: map[*goja.Object]*goja.Object{}}
+			return c.clone(call.Argument(0))
+		},
+	})
+}
+
+// cloneScript returns the helpers of the cloner. type tells the type of an
+// object by calling the builtin methods checking its internal slots, which
+// throw for other objects, "" for ordinary objects.
+const cloneScript = `(function () {
+	"use strict";
+	var apply = Reflect.apply, getDesc = Object.getOwnPropertyDescriptor, getProto = Object.getPrototypeOf,
+		isArray = Array.isArray, Object_ = Object, Array_ = Array, Date_ = Date, RegExp_ = RegExp,
+		Map_ = Map, Set_ = Set, DataView_ = DataView;
+	function getter(proto, name) {
+		return getDesc(proto, name).get;
+	}
+	var TypedArray = getProto(Int8Array.prototype);
+	var typedArrayTag = getter(TypedArray, Symbol.toStringTag);
+	var views = {
+		Int8Array: Int8Array, Uint8Array: Uint8Array, Uint8ClampedArray: Uint8ClampedArray,
+		Int16Array: Int16Array, Uint16Array: Uint16Array, Int32Array: Int32Array, Uint32Array: Uint32Array,
+		Float32Array: Float32Array, Float64Array: Float64Array,
+		BigInt64Array: BigInt64Array, BigUint64Array: BigUint64Array, DataView: DataView_
+	};
+	var errors = {
+		Error: Error, EvalError: EvalError, RangeError: RangeError, ReferenceError: ReferenceError,
+		SyntaxError: SyntaxError, TypeError: TypeError, URIError: URIError
+	};
+	var valueOf = {
+		Boolean: Boolean.prototype.valueOf, Number: Number.prototype.valueOf,
+		String: String.prototype.valueOf, BigInt: BigInt.prototype.valueOf
+	};
+	var getTime = Date.prototype.getTime, source = getter(RegExp.prototype, "source"),
+		flags = getter(RegExp.prototype, "flags"), slice = ArrayBuffer.prototype.slice,
+		mapForEach = Map.prototype.forEach, mapSet = Map.prototype.set,
+		setForEach = Set.prototype.forEach, setAdd = Set.prototype.add;
+	var brands = [
+		["Date", getTime], ["RegExp", source], ["Map", Map.prototype.has], ["Set", Set.prototype.has],
+		["ArrayBuffer", getter(ArrayBuffer.prototype, "byteLength")],
+		["DataView", getter(DataView.prototype, "byteLength")],
+		["Boolean", valueOf.Boolean], ["Number", valueOf.Number], ["String", valueOf.String],
+		["BigInt", valueOf.BigInt], ["Symbol", Symbol.prototype.valueOf],
+		["WeakMap", WeakMap.prototype.has], ["WeakSet", WeakSet.prototype.has]
+	];
+	var viewGetters = {
+		TypedArray: [getter(TypedArray, "buffer"), getter(TypedArray, "byteOffset"), getter(TypedArray, "length")],
+		DataView: [getter(DataView.prototype, "buffer"), getter(DataView.prototype, "byteOffset"),
+			getter(DataView.prototype, "byteLength")]
+	};
+	return {
+		type: function (obj) {
+			if (isArray(obj)) {
+				return "Array";
+			}
+			var tag = apply(typedArrayTag, obj, []);
+			if (tag !== undefined) {
+				return tag;
+			}
+			for (var i = 0; i < brands.length; i++) {
+				try {
+					apply(brands[i][1], obj, []);
+					return brands[i][0];
+				} catch (e) {}
+			}
+			return "";
+		},
+		// copy copies the objects without nested values
+		copy: function (obj, type) {
+			switch (type) {
+			case "Date":
+				return new Date_(apply(getTime, obj, []));
+			case "RegExp":
+				return new RegExp_(apply(source, obj, []), apply(flags, obj, []));
+			case "ArrayBuffer":
+				return apply(slice, obj, [0]);
+			default:
+				return Object_(apply(valueOf[type], obj, []));
+			}
+		},
+		entries: function (obj, type) {
+			var out = [];
+			if (type === "Map") {
+				apply(mapForEach, obj, [function (v, k) { out[out.length] = [k, v]; }]);
+			} else {
+				apply(setForEach, obj, [function (v) { out[out.length] = v; }]);
+			}
+			return out;
+		},
+		collection: function (type) {
+			return type === "Map" ? new Map_() : new Set_();
+		},
+		add: function (collection, type, key, value) {
+			if (type === "Map") {
+				apply(mapSet, collection, [key, value]);
+			} else {
+				apply(setAdd, collection, [key]);
+			}
+		},
+		// view returns the buffer, offset and length of a typed array or DataView
+		view: function (obj, type) {
+			var g = viewGetters[type === "DataView" ? type : "TypedArray"];
+			return [apply(g[0], obj, []), apply(g[1], obj, []), apply(g[2], obj, [])];
+		},
+		newView: function (type, buffer, offset, length) {
+			return new views[type](buffer, offset, length);
+		},
+		newError: function (name, message) {
+			return new (errors[name] || errors.Error)(message);
+		},
+		newArray: function () {
+			return new Array_();
+		},
+		newObject: function () {
+			return {};
+		}
+	};
+})()`
+
+var promiseType = reflect.TypeOf((*goja.Promise)(nil))
+
+type cloneHelpers struct {
+	typeOf, copy, entries, collection, add, view, newView, newError, newArray, newObject goja.Callable
+}
+
+type cloner struct {
+	runtime *goja.Runtime
+	helpers *cloneHelpers
+	seen    map[*goja.Object]*goja.Object
+}
+
+func (c *cloner) clone(v goja.Value) goja.Value {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if _, isSymbol := v.(*goja.Symbol); isSymbol {
+			panic(domException(c.runtime, "DataCloneError", "Symbol("+v.String()+") could not be cloned."))
+		}
+		return v
+	}
+	if clone, ok := c.seen[obj]; ok {
+		return clone
+	}
+	if _, isFunc := goja.AssertFunction(obj); isFunc {
+		panic(domException(c.runtime, "DataCloneError", obj.String()+" could not be cloned."))
+	}
+
+	typ := c.call(c.helpers.typeOf, obj).String()
+	switch {
+	case typ != "":
+	case obj.ExportType() == promiseType:
+		typ = "Promise"
+	default:
+		// the class of errors and arguments is internal as well
+		typ = obj.ClassName()
+	}
+	switch typ {
+	case "Array":
+		clone := c.object(c.helpers.newArray)
+		c.seen[obj] = clone
+		for i, n := int64(0), obj.Get("length").ToInteger(); i < n; i++ {
+			key := strconv.FormatInt(i, 10)
+			_ = clone.Set(key, c.clone(obj.Get(key)))
+		}
+		return clone
+	case "Object", "Arguments":
+		clone := c.object(c.helpers.newObject)
+		c.seen[obj] = clone
+		for _, key := range obj.Keys() {
+			_ = clone.Set(key, c.clone(obj.Get(key)))
+		}
+		return clone
+	case "Date", "RegExp", "ArrayBuffer", "Boolean", "Number", "String", "BigInt":
+		return c.remember(obj, c.object(c.helpers.copy, obj, c.runtime.ToValue(typ)))
+	case "Error":
+		name, message := obj.Get("name"), obj.Get("message")
+		if name == nil {
+			name = goja.Undefined()
+		}
+		if message == nil {
+			message = goja.Undefined()
+		}
+		return c.remember(obj, c.object(c.helpers.newError, c.runtime.ToValue(name.String()), message))
+	case "DataView", "Int8Array", "Uint8Array", "Uint8ClampedArray", "Int16Array", "Uint16Array",
+		"Int32Array", "Uint32Array", "Float32Array", "Float64Array", "BigInt64Array", "BigUint64Array":
+		view := c.object(c.helpers.view, obj, c.runtime.ToValue(typ))
+		buffer := c.clone(view.Get("0"))
+		return c.remember(obj, c.object(c.helpers.newView, c.runtime.ToValue(typ), buffer, view.Get("1"), view.Get("2")))
+	case "Map", "Set":
+		clone := c.object(c.helpers.collection, c.runtime.ToValue(typ))
+		c.seen[obj] = clone
+		var items []goja.Value
+		if err := c.runtime.ExportTo(c.call(c.helpers.entries, obj, c.runtime.ToValue(typ)), &items); err != nil {
+			panic(err)
+		}
+		for _, item := range items {
+			key, value := item, goja.Value(goja.Undefined())
+			if typ == "Map" {
+				pair := item.(*goja.Object)
+				key, value = pair.Get("0"), pair.Get("1")
+			}
+			c.call(c.helpers.add, clone, c.runtime.ToValue(typ), c.clone(key), c.clone(value))
+		}
+		return clone
+	}
+	panic(domException(c.runtime, "DataCloneError", "#<"+typ+"> could not be cloned."))
+}
+
+func (c *cloner) remember(obj, clone *goja.Object) *goja.Object {
+	c.seen[obj] = clone
+	return clone
+}
+
+// call calls the helper fn.
+func (c *cloner) call(fn goja.Callable, args ...goja.Value) goja.Value {
+	result, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// object calls the helper fn, which returns an object.
+func (c *cloner) object(fn goja.Callable, args ...goja.Value) *goja.Object {
+	return c.call(fn, args...).(*goja.Object)
+}
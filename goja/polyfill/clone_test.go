@@ -0,0 +1,83 @@
+package polyfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredClone(t *testing.T) {
+	vm := newRuntime(t, StructuredClone)
+
+	v := run(t, vm, `
+var date = new Date(0);
+var bytes = new Uint8Array([1, 2, 3]);
+var original = {
+	n: 1, s: "s", b: true, nil: null, big: 10n,
+	list: [1, {deep: true}],
+	date: date,
+	re: /a+/gi,
+	map: new Map([[{k: 1}, "object key"], ["d", date]]),
+	set: new Set([1, "two"]),
+	bytes: bytes,
+	view: bytes.subarray(1),
+	buffer: new ArrayBuffer(2),
+	dv: new DataView(bytes.buffer, 1, 1),
+	err: new RangeError("too far"),
+	boxed: new Number(3),
+	instance: new (class Point { constructor() { this.x = 1; } })(),
+};
+original.self = original;
+var c = structuredClone(original);
+bytes[0] = 9;
+original.list[1].deep = false;
+[
+	c !== original, c.self === c, c.n, c.s, c.b, c.nil, typeof c.big, c.list[1].deep,
+	c.date instanceof Date && c.date !== date && c.date.getTime() === 0,
+	c.re.source + "/" + c.re.flags, c.map.size, c.map.get("d") === c.date,
+	Array.from(c.map.keys())[0].k, c.set.has("two"),
+	c.bytes.join(), c.view.join(), c.view.buffer === c.bytes.buffer, c.buffer.byteLength,
+	c.dv.getUint8(0), c.err instanceof RangeError, c.err.message, c.boxed instanceof Number,
+	Number(c.boxed), Object.getPrototypeOf(c.instance) === Object.prototype, c.instance.x,
+].join(" ")
+`)
+	assert.Equal(t, "true true 1 s true  bigint true true a+/gi 2 true 1 true 1,2,3 2,3 true 2 2 true too far true 3 true 1", v.String())
+
+	for src, want := range map[string]string{
+		`structuredClone(function f() {})`:                         "DataCloneError: function f() {} could not be cloned.",
+		`structuredClone({s: Symbol("x")})`:                        "DataCloneError: Symbol(x) could not be cloned.",
+		`structuredClone(Promise.resolve())`:                       "DataCloneError: #<Promise> could not be cloned.",
+		`structuredClone([new WeakMap()])`:                         "DataCloneError: #<WeakMap> could not be cloned.",
+		`structuredClone()`:                                        "TypeError: structuredClone: 1 argument required",
+		`structuredClone({get x() { throw new Error("getter") }})`: "Error: getter",
+	} {
+		_, err := vm.RunString(src)
+		assert.ErrorContains(t, err, want, src)
+	}
+	assert.Equal(t, "1", run(t, vm, `structuredClone(1)`).String())
+
+	// 类型由内部 slot 决定，伪造的 Symbol.toStringTag 只会被当作普通对象复制
+	v = run(t, vm, `
+[
+	structuredClone({[Symbol.toStringTag]: "Date", a: 1}),
+	structuredClone({[Symbol.toStringTag]: "ArrayBuffer", a: 1}),
+	structuredClone({[Symbol.toStringTag]: "Map", a: 1}),
+	structuredClone(Object.setPrototypeOf(new Error("bare"), null)),
+].map(function (c) { return Object.prototype.toString.call(c) + JSON.stringify(c); }).join(" ")
+`)
+	assert.Equal(t, `[object Object]{"a":1} [object Object]{"a":1} [object Object]{"a":1} [object Error]{}`, v.String())
+
+	// 替换或删除内置对象后依然可用
+	v = run(t, vm, `
+var date = new Date(0);
+Object.prototype.toString = function () { return "[object Map]"; };
+Date.prototype.getTime = function () { return 1; };
+Map.prototype.forEach = null;
+delete globalThis.Array;
+delete globalThis.Uint8Array;
+delete globalThis.RangeError;
+var c = structuredClone({date: date, map: new Map([[1, 2]]), bytes: new Int8Array([1]), err: new TypeError("t")});
+[c.date.valueOf(), c.map.get(1), c.bytes[0], c.err.message].join(" ")
+`)
+	assert.Equal(t, "0 2 1 t", v.String())
+}
@@ -0,0 +1,39 @@
+package polyfill
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// Crypto sets crypto.randomUUID and crypto.getRandomValues, backed by
+// crypto/rand.
+func Crypto(runtime *goja.Runtime) error {
+	crypto := runtime.NewObject()
+	method(runtime, crypto, "randomUUID", func(goja.FunctionCall) goja.Value {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(runtime.NewGoError(err))
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return runtime.ToValue(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]))
+	})
+	method(runtime, crypto, "getRandomValues", func(call goja.FunctionCall) goja.Value {
+		arr := call.Argument(0)
+		obj, isObj := arr.(*goja.Object)
+		data, ok := bufferSource(runtime, arr)
+		if !ok || !isObj || obj.Get("BYTES_PER_ELEMENT") == nil {
+			panic(runtime.NewTypeError("getRandomValues: argument 1 must be an integer typed array"))
+		}
+		if len(data) > 65536 {
+			panic(domException(runtime, "QuotaExceededError", fmt.Sprintf("The ArrayBufferView's byte length (%d) exceeds the number of bytes of entropy available via this API (65536).", len(data))))
+		}
+		if _, err := rand.Read(data); err != nil {
+			panic(runtime.NewGoError(err))
+		}
+		return arr
+	})
+	return setGlobals(runtime, map[string]any{"crypto": crypto})
+}
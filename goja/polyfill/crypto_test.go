@@ -0,0 +1,39 @@
+package polyfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrypto(t *testing.T) {
+	vm := newRuntime(t, Crypto)
+
+	v := run(t, vm, `
+var seen = {};
+for (var i = 0; i < 100; i++) {
+	seen[crypto.randomUUID()] = true;
+}
+Object.keys(seen)
+`)
+	uuids := v.Export().([]any)
+	assert.Len(t, uuids, 100)
+	for _, uuid := range uuids {
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, uuid)
+	}
+
+	v = run(t, vm, `
+var arr = new Uint32Array(8);
+var same = crypto.getRandomValues(arr) === arr;
+same && arr.some(function (n) { return n !== 0; })
+`)
+	assert.Equal(t, true, v.Export())
+
+	for src, want := range map[string]string{
+		`crypto.getRandomValues(new ArrayBuffer(4))`:    "TypeError: getRandomValues: argument 1 must be an integer typed array",
+		`crypto.getRandomValues(new Uint8Array(65537))`: "QuotaExceededError",
+	} {
+		v := run(t, vm, `try { `+src+`; "no error" } catch (e) { e.name + ": " + e.message }`)
+		assert.Contains(t, v.String(), want, src)
+	}
+}
@@ -0,0 +1,155 @@
+package polyfill
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// TextEncoding sets TextEncoder and TextDecoder, for UTF-8 only.
+func TextEncoding(runtime *goja.Runtime) error {
+	return setGlobals(runtime, map[string]any{
+		"TextEncoder": func(call goja.ConstructorCall) *goja.Object {
+			newTextEncoder(runtime, call.This)
+			return nil
+		},
+		"TextDecoder": func(call goja.ConstructorCall) *goja.Object {
+			newTextDecoder(runtime, call)
+			return nil
+		},
+	})
+}
+
+func newTextEncoder(runtime *goja.Runtime, obj *goja.Object) {
+	accessor(runtime, obj, "encoding", func() goja.Value { return runtime.ToValue("utf-8") }, nil)
+	method(runtime, obj, "encode", func(call goja.FunctionCall) goja.Value {
+		s := ""
+		if v := call.Argument(0); !goja.IsUndefined(v) {
+			s = v.String()
+		}
+		return newUint8Array(runtime, []byte(s))
+	})
+	method(runtime, obj, "encodeInto", func(call goja.FunctionCall) goja.Value {
+		dest, ok := bufferSource(runtime, call.Argument(1))
+		if !ok {
+			panic(runtime.NewTypeError("encodeInto: argument 2 must be a Uint8Array"))
+		}
+		read, written := 0, 0
+		for _, r := range call.Argument(0).String() {
+			n := utf8.RuneLen(r)
+			if written+n > len(dest) {
+				break
+			}
+			utf8.EncodeRune(dest[written:], r)
+			written += n
+			// read counts UTF-16 code units
+			read++
+			if r > 0xFFFF {
+				read++
+			}
+		}
+		return runtime.ToValue(map[string]any{"read": read, "written": written})
+	})
+}
+
+func newTextDecoder(runtime *goja.Runtime, call goja.ConstructorCall) {
+	if label := call.Argument(0); !goja.IsUndefined(label) {
+		switch strings.ToLower(strings.TrimSpace(label.String())) {
+		case "utf-8", "utf8", "unicode-1-1-utf-8":
+		default:
+			panic(newError(runtime, "RangeError", "The encoding label provided ('"+label.String()+"') is invalid."))
+		}
+	}
+	fatal, ignoreBOM := option(call.Argument(1), "fatal"), option(call.Argument(1), "ignoreBOM")
+
+	obj := call.This
+	accessor(runtime, obj, "encoding", func() goja.Value { return runtime.ToValue("utf-8") }, nil)
+	accessor(runtime, obj, "fatal", func() goja.Value { return runtime.ToValue(fatal) }, nil)
+	accessor(runtime, obj, "ignoreBOM", func() goja.Value { return runtime.ToValue(ignoreBOM) }, nil)
+	method(runtime, obj, "decode", func(call goja.FunctionCall) goja.Value {
+		var data []byte
+		if v := call.Argument(0); !goja.IsUndefined(v) {
+			var ok bool
+			if data, ok = bufferSource(runtime, v); !ok {
+				panic(runtime.NewTypeError("decode: argument 1 must be an ArrayBuffer or a view on one"))
+			}
+		}
+		if !ignoreBOM {
+			data = []byte(strings.TrimPrefix(string(data), "\ufeff"))
+		}
+		if fatal && !utf8.Valid(data) {
+			panic(runtime.NewTypeError("The encoded data was not valid for encoding utf-8"))
+		}
+		return runtime.ToValue(decodeUTF8(data))
+	})
+}
+
+// option reports whether the boolean option name is set in opts.
+func option(opts goja.Value, name string) bool {
+	obj, ok := opts.(*goja.Object)
+	if !ok {
+		return false
+	}
+	v := obj.Get(name)
+	return v != nil && v.ToBoolean()
+}
+
+// decodeUTF8 replaces each invalid byte with U+FFFD.
+func decodeUTF8(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	var b strings.Builder
+	for len(data) > 0 {
+		r, n := utf8.DecodeRune(data)
+		b.WriteRune(r)
+		data = data[n:]
+	}
+	return b.String()
+}
+
+// Base64 sets atob and btoa.
+func Base64(runtime *goja.Runtime) error {
+	return setGlobals(runtime, map[string]any{
+		"btoa": func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) == 0 {
+				panic(runtime.NewTypeError("btoa: 1 argument required, but only 0 present"))
+			}
+			s := call.Argument(0).String()
+			data := make([]byte, 0, len(s))
+			for _, r := range s {
+				if r > 0xFF {
+					panic(domException(runtime, "InvalidCharacterError", "Invalid character"))
+				}
+				data = append(data, byte(r))
+			}
+			return runtime.ToValue(base64.StdEncoding.EncodeToString(data))
+		},
+		"atob": func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) == 0 {
+				panic(runtime.NewTypeError("atob: 1 argument required, but only 0 present"))
+			}
+			s := strings.Map(func(r rune) rune {
+				if strings.ContainsRune("\t\n\f\r ", r) {
+					return -1
+				}
+				return r
+			}, call.Argument(0).String())
+			if len(s)%4 == 0 {
+				s = strings.TrimSuffix(strings.TrimSuffix(s, "="), "=")
+			}
+			data, err := base64.RawStdEncoding.DecodeString(s)
+			if err != nil || len(s)%4 == 1 {
+				panic(domException(runtime, "InvalidCharacterError", "The string to be decoded is not correctly encoded."))
+			}
+			// one char per byte, as the strings of btoa
+			runes := make([]rune, len(data))
+			for i, c := range data {
+				runes[i] = rune(c)
+			}
+			return runtime.ToValue(string(runes))
+		},
+	})
+}
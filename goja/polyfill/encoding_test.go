@@ -0,0 +1,58 @@
+package polyfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextEncoding(t *testing.T) {
+	vm := newRuntime(t, TextEncoding)
+
+	for src, want := range map[string]any{
+		`Array.from(new TextEncoder().encode("hé€😀")).join(",")`:                                                                      "104,195,169,226,130,172,240,159,152,128",
+		`new TextEncoder().encode().length`:                                                                                           int64(0),
+		`new TextEncoder().encoding`:                                                                                                  "utf-8",
+		`new TextDecoder().decode(new TextEncoder().encode("hé€😀"))`:                                                                  "hé€😀",
+		`new TextDecoder("UTF-8").decode(new Uint8Array([0xEF, 0xBB, 0xBF, 0x61]).buffer)`:                                            "a",
+		`new TextDecoder("utf-8", {ignoreBOM: true}).decode(new Uint8Array([0xEF, 0xBB, 0xBF])).length`:                               int64(1),
+		`new TextDecoder().decode(new Uint8Array([0x61, 0xFF, 0x62]))`:                                                                "a�b",
+		`new TextDecoder().decode(new DataView(new Uint8Array([0x61, 0x62, 0x63]).buffer, 1, 1))`:                                     "b",
+		`new TextDecoder().decode(new Uint8Array([0x61, 0x62, 0x63]).subarray(2))`:                                                    "c",
+		`new TextDecoder().decode()`:                                                                                                  "",
+		`var buf = new Uint8Array(4); var r = new TextEncoder().encodeInto("a😀b", buf); [r.read, r.written, buf.join(",")].join(" ")`: "1 1 97,0,0,0",
+		`var buf = new Uint8Array(6); var r = new TextEncoder().encodeInto("a😀b", buf); [r.read, r.written].join(" ")`:                "4 6",
+	} {
+		assert.Equal(t, want, run(t, vm, src).Export(), src)
+	}
+
+	for src, want := range map[string]string{
+		`new TextDecoder("latin1")`: "RangeError: The encoding label provided ('latin1') is invalid.",
+		`new TextDecoder("utf-8", {fatal: true}).decode(new Uint8Array([0xFF]))`: "TypeError: The encoded data was not valid for encoding utf-8",
+		`new TextDecoder().decode("text")`:                                       "TypeError: decode: argument 1 must be an ArrayBuffer or a view on one",
+	} {
+		_, err := vm.RunString(src)
+		assert.ErrorContains(t, err, want, src)
+	}
+}
+
+func TestBase64(t *testing.T) {
+	vm := newRuntime(t, Base64)
+
+	for src, want := range map[string]string{
+		`btoa("hello")`:                         "aGVsbG8=",
+		`btoa("\xff\xfe")`:                      "//4=",
+		`atob("aGVsbG8=")`:                      "hello",
+		`atob("aGVsbG8")`:                       "hello",
+		`atob(" aGVs\nbG8= ")`:                  "hello",
+		`atob("//4=").charCodeAt(0).toString()`: "255",
+		`atob(btoa("\x00\x01"))`:                "\x00\x01",
+	} {
+		assert.Equal(t, want, run(t, vm, src).String(), src)
+	}
+
+	for _, src := range []string{`btoa("€")`, `atob("a")`, `atob("a===")`, `atob("*")`} {
+		v := run(t, vm, `try { `+src+`; "no error" } catch (e) { e.name }`)
+		assert.Equal(t, "InvalidCharacterError", v.String(), src)
+	}
+}
@@ -0,0 +1,288 @@
+package polyfill
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// Async starts the asynchronous operations of fetch, as Loop.Go and
+// Loop.RunOnLoop of the parent package do: Go runs fn in its own goroutine
+// and keeps the run going until fn returns, RunOnLoop runs fn on the loop
+// unless the run is over.
+type Async interface {
+	Go(fn func(ctx context.Context) (any, error)) *goja.Promise
+	RunOnLoop(fn func(runtime *goja.Runtime))
+}
+
+// FetchOptions configures Fetch.
+type FetchOptions struct {
+	// Transport sends the requests, http.DefaultTransport when nil. Tests
+	// typically pass the Client().Transport of an httptest.Server.
+	Transport http.RoundTripper
+	// MaxResponseBytes caps the response bodies, 10MB when 0. A longer body
+	// rejects the promise returned by fetch.
+	MaxResponseBytes int64
+}
+
+// Fetch sets fetch and Headers. The requests are sent from the goroutines
+// of async with the context it passes, for a Loop the one of Loop.Run:
+// canceling the context of the run aborts them. Redirects are followed.
+//
+// A request that could not be sent rejects the promise with a GoError
+// wrapping the error of the transport, HTTP error statuses resolve it as
+// browsers do. The response body is read before the promise resolves.
+func Fetch(runtime *goja.Runtime, async Async, opts FetchOptions) error {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = 10 << 20
+	}
+	client := &http.Client{Transport: opts.Transport}
+
+	return setGlobals(runtime, map[string]any{
+		"Headers": func(call goja.ConstructorCall) *goja.Object {
+			h := http.Header{}
+			fillHeaders(runtime, h, call.Argument(0))
+			newHeaders(runtime, call.This, h)
+			return nil
+		},
+		"fetch": func(call goja.FunctionCall) goja.Value {
+			req, err := newRequest(runtime, call.Argument(0), call.Argument(1))
+			if err != nil {
+				return rejected(runtime, runtime.NewTypeError("fetch: "+err.Error()))
+			}
+			// the promise is settled from Go, the response object made on the
+			// loop, before the goroutine returns
+			promise, resolve, reject := runtime.NewPromise()
+			async.Go(func(ctx context.Context) (any, error) {
+				resp, err := do(ctx, client, req, opts.MaxResponseBytes)
+				settled := make(chan struct{})
+				async.RunOnLoop(func(runtime *goja.Runtime) {
+					defer close(settled)
+					if err != nil {
+						reject(runtime.NewGoError(err))
+					} else {
+						resolve(newResponse(runtime, resp))
+					}
+				})
+				select {
+				case <-settled:
+				case <-ctx.Done():
+				}
+				return nil, nil
+			})
+			return runtime.ToValue(promise)
+		},
+	})
+}
+
+func newRequest(runtime *goja.Runtime, input, init goja.Value) (*http.Request, error) {
+	rawURL := input.String()
+	if u, ok := unwrap[*url.URL](input); ok {
+		rawURL = u.String()
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.Errorf("invalid URL %q", rawURL)
+	}
+
+	method := http.MethodGet
+	header := http.Header{}
+	var body []byte
+	if opts, ok := init.(*goja.Object); ok {
+		if v := opts.Get("method"); v != nil && !goja.IsUndefined(v) {
+			method = strings.ToUpper(v.String())
+		}
+		fillHeaders(runtime, header, opts.Get("headers"))
+		if v := opts.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			if method == http.MethodGet || method == http.MethodHead {
+				return nil, errors.Errorf("request with %s method cannot have body", method)
+			}
+			var contentType string
+			body, contentType = requestBody(runtime, v)
+			if header.Get("Content-Type") == "" && contentType != "" {
+				header.Set("Content-Type", contentType)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
+
+// requestBody returns the bytes of a body, copied from the JS values, and
+// its default content type.
+func requestBody(runtime *goja.Runtime, v goja.Value) ([]byte, string) {
+	if p, ok := unwrap[*searchParams](v); ok {
+		return []byte(p.String()), "application/x-www-form-urlencoded;charset=UTF-8"
+	}
+	if data, ok := bufferSource(runtime, v); ok {
+		return bytes.Clone(data), ""
+	}
+	return []byte(v.String()), "text/plain;charset=UTF-8"
+}
+
+type fetchResponse struct {
+	url        string
+	status     int
+	statusText string
+	redirected bool
+	header     http.Header
+	body       []byte
+}
+
+func do(ctx context.Context, client *http.Client, req *http.Request, maxBytes int64) (*fetchResponse, error) {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch failed")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch failed to read the response")
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, errors.Errorf("fetch: response body exceeds %d bytes", maxBytes)
+	}
+	return &fetchResponse{
+		url:        resp.Request.URL.String(),
+		status:     resp.StatusCode,
+		statusText: strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "),
+		redirected: resp.Request.URL.String() != req.URL.String(),
+		header:     resp.Header,
+		body:       body,
+	}, nil
+}
+
+func newResponse(runtime *goja.Runtime, r *fetchResponse) *goja.Object {
+	obj := runtime.NewObject()
+	value := func(v any) func() goja.Value {
+		return func() goja.Value { return runtime.ToValue(v) }
+	}
+	accessor(runtime, obj, "url", value(r.url), nil)
+	accessor(runtime, obj, "status", value(r.status), nil)
+	accessor(runtime, obj, "statusText", value(r.statusText), nil)
+	accessor(runtime, obj, "ok", value(r.status >= 200 && r.status < 300), nil)
+	accessor(runtime, obj, "redirected", value(r.redirected), nil)
+	headers := runtime.NewObject()
+	newHeaders(runtime, headers, r.header)
+	accessor(runtime, obj, "headers", func() goja.Value { return headers }, nil)
+
+	used := false
+	accessor(runtime, obj, "bodyUsed", func() goja.Value { return runtime.ToValue(used) }, nil)
+	// read reads the body once, as the body of a response is a stream
+	read := func(convert func() goja.Value) func(goja.FunctionCall) goja.Value {
+		return func(goja.FunctionCall) goja.Value {
+			if used {
+				return rejected(runtime, runtime.NewTypeError("Body has already been consumed."))
+			}
+			used = true
+			var v goja.Value
+			if err := runtime.Try(func() { v = convert() }); err != nil {
+				return rejected(runtime, err.Value())
+			}
+			return resolved(runtime, v)
+		}
+	}
+	method(runtime, obj, "text", read(func() goja.Value { return runtime.ToValue(decodeUTF8(r.body)) }))
+	method(runtime, obj, "json", read(func() goja.Value {
+		v, err := builtinFunc(runtime, "JSON.parse")(nil, runtime.ToValue(decodeUTF8(r.body)))
+		if err != nil {
+			panic(err)
+		}
+		return v
+	}))
+	method(runtime, obj, "arrayBuffer", read(func() goja.Value {
+		return runtime.ToValue(runtime.NewArrayBuffer(bytes.Clone(r.body)))
+	}))
+	method(runtime, obj, "bytes", read(func() goja.Value { return newUint8Array(runtime, bytes.Clone(r.body)) }))
+	return obj
+}
+
+func resolved(runtime *goja.Runtime, v any) goja.Value {
+	promise, resolve, _ := runtime.NewPromise()
+	resolve(v)
+	return runtime.ToValue(promise)
+}
+
+func rejected(runtime *goja.Runtime, reason any) goja.Value {
+	promise, _, reject := runtime.NewPromise()
+	reject(reason)
+	return runtime.ToValue(promise)
+}
+
+// fillHeaders adds to h the headers of init: a Headers, a sequence of pairs
+// or a record.
+func fillHeaders(runtime *goja.Runtime, h http.Header, init goja.Value) {
+	if other, ok := unwrap[http.Header](init); ok {
+		for name, values := range other {
+			h[name] = append(h[name], values...)
+		}
+		return
+	}
+	p := &searchParams{}
+	p.init(runtime, init)
+	for _, pair := range p.list {
+		h.Add(pair[0], pair[1])
+	}
+}
+
+func newHeaders(runtime *goja.Runtime, obj *goja.Object, h http.Header) {
+	name := func(call goja.FunctionCall) string {
+		return http.CanonicalHeaderKey(call.Argument(0).String())
+	}
+	// list returns the headers sorted by lowercase name, the values of a name
+	// combined
+	list := func() [][2]string {
+		var names []string
+		for name := range h {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([][2]string, len(names))
+		for i, name := range names {
+			pairs[i] = [2]string{strings.ToLower(name), strings.Join(h[name], ", ")}
+		}
+		return pairs
+	}
+
+	method(runtime, obj, "get", func(call goja.FunctionCall) goja.Value {
+		values, ok := h[name(call)]
+		if !ok {
+			return goja.Null()
+		}
+		return runtime.ToValue(strings.Join(values, ", "))
+	})
+	method(runtime, obj, "has", func(call goja.FunctionCall) goja.Value {
+		_, ok := h[name(call)]
+		return runtime.ToValue(ok)
+	})
+	method(runtime, obj, "set", func(call goja.FunctionCall) goja.Value {
+		h.Set(name(call), call.Argument(1).String())
+		return goja.Undefined()
+	})
+	method(runtime, obj, "append", func(call goja.FunctionCall) goja.Value {
+		h.Add(name(call), call.Argument(1).String())
+		return goja.Undefined()
+	})
+	method(runtime, obj, "delete", func(call goja.FunctionCall) goja.Value {
+		h.Del(name(call))
+		return goja.Undefined()
+	})
+	pairMethods(runtime, obj, "Headers", list)
+	wrap(runtime, obj, h)
+}
@@ -0,0 +1,175 @@
+package polyfill
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	gojax "github.com/molon/tests/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFetchServer(t *testing.T, canceled chan<- struct{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 1, "token": r.Header.Get("X-Token"), "query": r.URL.RawQuery})
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, r.Method+" "+r.Header.Get("Content-Type")+" "+string(body))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 1024))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFetchLoop(t *testing.T, server *httptest.Server, opts FetchOptions) (*goja.Runtime, *gojax.Loop) {
+	vm := goja.New()
+	loop, err := gojax.NewLoop(vm)
+	require.NoError(t, err)
+	opts.Transport = server.Client().Transport
+	require.NoError(t, Fetch(vm, loop, opts))
+	require.NoError(t, URL(vm))
+	require.NoError(t, vm.Set("base", server.URL))
+	return vm, loop
+}
+
+func script(src string) func(runtime *goja.Runtime) (goja.Value, error) {
+	return func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunScript("fetch.js", src)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := newFetchServer(t, nil)
+	_, loop := newFetchLoop(t, server, FetchOptions{MaxResponseBytes: 512})
+
+	result, err := loop.Run(context.Background(), script(`
+(async function () {
+	var r = await fetch(base + "/users/1?x=1", {headers: {"X-Token": "secret"}});
+	var body = await r.json();
+	var again = await r.text().catch(function (e) { return e.message; });
+	return {
+		status: r.status, ok: r.ok, statusText: r.statusText, url: r.url,
+		type: r.headers.get("content-type"), multi: r.headers.get("x-multi"),
+		body: body, used: r.bodyUsed, again: again,
+	};
+})()
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"status": int64(200), "ok": true, "statusText": "OK", "url": server.URL + "/users/1?x=1",
+		"type": "application/json", "multi": "a, b",
+		"body": map[string]any{"id": int64(1), "token": "secret", "query": "x=1"},
+		"used": true, "again": "Body has already been consumed.",
+	}, result.Value.Export())
+
+	for src, want := range map[string]any{
+		`fetch(base + "/echo", {method: "post", body: new URLSearchParams({q: "a b"})}).then(r => r.text())`:                                                                  "POST application/x-www-form-urlencoded;charset=UTF-8 q=a+b",
+		`fetch(new URL("/echo", base), {method: "PUT", body: "plain"}).then(r => r.text())`:                                                                                   "PUT text/plain;charset=UTF-8 plain",
+		`fetch(base + "/echo", {method: "POST", body: new Uint8Array([104, 105]), headers: new Headers([["Content-Type", "application/octet-stream"]])}).then(r => r.text())`: "POST application/octet-stream hi",
+		`fetch(base + "/moved").then(r => [r.redirected, r.url, r.status].join(" "))`:                                                                                         "true " + server.URL + "/echo 200",
+		`fetch(base + "/missing").then(r => [r.ok, r.status, r.statusText].join(" "))`:                                                                                        "false 404 Not Found",
+		`fetch(base + "/echo").then(r => r.arrayBuffer()).then(b => b.byteLength)`:                                                                                            int64(5),
+		`fetch(base + "/echo").then(r => r.bytes()).then(b => b[0])`:                                                                                                          int64('G'),
+		`fetch("ftp://example.com").catch(e => e.name + ": " + e.message)`:                                                                                                    `TypeError: fetch: invalid URL "ftp://example.com"`,
+		`fetch(base, {body: "x"}).catch(e => e.message)`:                                                                                                                      "fetch: request with GET method cannot have body",
+		`fetch(base + "/big").catch(e => e.message)`:                                                                                                                          "fetch: response body exceeds 512 bytes",
+		`fetch(base + "/echo").then(r => r.json()).catch(e => e.name)`:                                                                                                        "SyntaxError",
+	} {
+		result, err := loop.Run(context.Background(), script(src))
+		require.NoError(t, err, src)
+		assert.Equal(t, want, result.Value.Export(), src)
+	}
+}
+
+func TestFetchNetworkError(t *testing.T) {
+	server := newFetchServer(t, nil)
+	_, loop := newFetchLoop(t, server, FetchOptions{})
+	server.Close()
+
+	result, err := loop.Run(context.Background(), script(`fetch(base + "/echo").catch(e => e.message)`))
+	require.NoError(t, err)
+	assert.Contains(t, result.Value.String(), "fetch failed")
+
+	// 没有 catch 时拒绝的原因会作为错误返回，且能 unwrap 到传输层的错误
+	_, err = loop.Run(context.Background(), script(`fetch(base + "/echo")`))
+	var exception *gojax.ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.ErrorContains(t, err, "fetch failed")
+}
+
+func TestFetchReplacedBuiltins(t *testing.T) {
+	server := newFetchServer(t, nil)
+	_, loop := newFetchLoop(t, server, FetchOptions{})
+
+	// 脚本删除或替换 fetch 依赖的内置对象时抛出 TypeError，而不是 Go 的 panic
+	result, err := loop.Run(context.Background(), script(`
+delete globalThis.JSON;
+fetch(base + "/users/1").then(r => r.json()).catch(e => e.name + ": " + e.message)
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "TypeError: JSON.parse is not available", result.Value.Export())
+
+	// fetch 不经过脚本可替换的 then 完成 promise
+	for _, then := range []string{"null", "function (f) { return f(1) }"} {
+		result, err = loop.Run(context.Background(), script(`
+Promise.prototype.then = `+then+`;
+fetch(base + "/echo")
+`))
+		require.NoError(t, err, then)
+		assert.Equal(t, int64(200), result.Value.ToObject(nil).Get("status").Export(), then)
+	}
+}
+
+func TestFetchCanceled(t *testing.T) {
+	canceled := make(chan struct{})
+	server := newFetchServer(t, canceled)
+	_, loop := newFetchLoop(t, server, FetchOptions{})
+
+	// 执行的 ctx 结束时请求随之取消
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := loop.Run(ctx, script(`fetch(base + "/slow")`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not canceled")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	vm := newRuntime(t, func(runtime *goja.Runtime) error { return Fetch(runtime, nil, FetchOptions{}) })
+
+	v := run(t, vm, `
+var h = new Headers({"X-B": "2", "x-a": "1"});
+h.append("X-A", "3");
+var copy = new Headers(h);
+h.delete("x-b");
+[
+	h.get("X-A"), h.has("x-b"), String(h.get("missing")), Array.from(copy).join(";"),
+	Array.from(copy.keys()).join(), copy.get("x-b"),
+].join(" | ")
+`)
+	assert.Equal(t, "1, 3 | false | null | x-a,1, 3;x-b,2 | x-a,x-b | 2", v.String())
+}
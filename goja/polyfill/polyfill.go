@@ -0,0 +1,193 @@
+// Package polyfill installs common web APIs into a goja runtime, each
+// installer setting the globals of one API: TextEncoding, Base64, URL,
+// StructuredClone, Crypto and Fetch. They are implemented in Go.
+package polyfill
+
+import (
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// internal is the key of the Go value behind the objects created by the
+// polyfills, scripts cannot reach it by name.
+var internal = goja.NewSymbol("polyfill")
+
+func setGlobals(runtime *goja.Runtime, globals map[string]any) error {
+	for name, v := range globals {
+		if err := runtime.Set(name, v); err != nil {
+			return errors.Wrapf(err, "failed to set %s", name)
+		}
+	}
+	return nil
+}
+
+// wrap attaches v to obj, unwrap gets it back.
+func wrap(runtime *goja.Runtime, obj *goja.Object, v any) {
+	// fresh objects accept any property
+	_ = obj.DefineDataPropertySymbol(internal, runtime.ToValue(v), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+}
+
+func unwrap[T any](v goja.Value) (T, bool) {
+	var zero T
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return zero, false
+	}
+	internal := obj.GetSymbol(internal)
+	if internal == nil {
+		return zero, false
+	}
+	t, ok := internal.Export().(T)
+	return t, ok
+}
+
+// method sets a non enumerable method on obj, like the methods of builtin
+// prototypes.
+func method(runtime *goja.Runtime, obj *goja.Object, name string, fn func(goja.FunctionCall) goja.Value) {
+	_ = obj.DefineDataProperty(name, runtime.ToValue(fn), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+}
+
+// accessor sets a non enumerable accessor on obj, read only when set is nil.
+func accessor(runtime *goja.Runtime, obj *goja.Object, name string, get func() goja.Value, set func(goja.Value)) {
+	var setter goja.Value
+	if set != nil {
+		setter = runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+			set(call.Argument(0))
+			return goja.Undefined()
+		})
+	}
+	getter := runtime.ToValue(func(goja.FunctionCall) goja.Value { return get() })
+	_ = obj.DefineAccessorProperty(name, getter, setter, goja.FLAG_TRUE, goja.FLAG_FALSE)
+}
+
+// pairMethods sets forEach, entries, keys, values and the iterator of obj,
+// a URLSearchParams or a Headers named typ, over the name value pairs
+// returned by list.
+func pairMethods(runtime *goja.Runtime, obj *goja.Object, typ string, list func() [][2]string) {
+	method(runtime, obj, "forEach", func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(runtime.NewTypeError(typ + ".forEach: argument 1 must be a function"))
+		}
+		for _, pair := range list() {
+			if _, err := fn(call.Argument(1), runtime.ToValue(pair[1]), runtime.ToValue(pair[0]), obj); err != nil {
+				panic(err)
+			}
+		}
+		return goja.Undefined()
+	})
+	// the iterators go over a copy, as the ones of arrays they are
+	iterator := func(item func(pair [2]string) any) func(goja.FunctionCall) goja.Value {
+		return func(goja.FunctionCall) goja.Value {
+			var items []any
+			for _, pair := range list() {
+				items = append(items, item(pair))
+			}
+			arr := runtime.NewArray(items...)
+			values := callable(runtime, arr.GetSymbol(goja.SymIterator), "Array.prototype[Symbol.iterator]")
+			it, err := values(arr)
+			if err != nil {
+				panic(err)
+			}
+			return it
+		}
+	}
+	entries := iterator(func(pair [2]string) any { return runtime.NewArray(pair[0], pair[1]) })
+	method(runtime, obj, "entries", entries)
+	method(runtime, obj, "keys", iterator(func(pair [2]string) any { return pair[0] }))
+	method(runtime, obj, "values", iterator(func(pair [2]string) any { return pair[1] }))
+	_ = obj.DefineDataPropertySymbol(goja.SymIterator, runtime.ToValue(entries), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+}
+
+// callable returns the function v, or throws a TypeError naming what:
+// scripts may have replaced the builtins the polyfills use.
+func callable(runtime *goja.Runtime, v goja.Value, what string) goja.Callable {
+	fn, ok := goja.AssertFunction(v)
+	if !ok {
+		panic(runtime.NewTypeError("%s is not a function", what))
+	}
+	return fn
+}
+
+// builtin returns the value at the dotted path from the global object, such
+// as "JSON.parse", or throws a TypeError when a part of it is missing.
+func builtin(runtime *goja.Runtime, path string) goja.Value {
+	var v goja.Value = runtime.GlobalObject()
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := v.(*goja.Object)
+		if !ok {
+			panic(runtime.NewTypeError("%s is not available", path))
+		}
+		v = obj.Get(name)
+	}
+	return v
+}
+
+// builtinFunc returns the builtin function at path, see builtin.
+func builtinFunc(runtime *goja.Runtime, path string) goja.Callable {
+	return callable(runtime, builtin(runtime, path), path)
+}
+
+// builtinConstructor returns the builtin constructor at path, see builtin.
+func builtinConstructor(runtime *goja.Runtime, path string) goja.Constructor {
+	ctor, ok := goja.AssertConstructor(builtin(runtime, path))
+	if !ok {
+		panic(runtime.NewTypeError("%s is not a constructor", path))
+	}
+	return ctor
+}
+
+// newError returns a new error of the builtin constructor ctor, such as
+// "RangeError".
+func newError(runtime *goja.Runtime, ctor, message string) *goja.Object {
+	obj, err := builtinConstructor(runtime, ctor)(nil, runtime.ToValue(message))
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// domException returns an Error named name, standing for the DOMException of
+// the browsers.
+func domException(runtime *goja.Runtime, name, message string) *goja.Object {
+	obj := newError(runtime, "Error", message)
+	_ = obj.DefineDataProperty("name", runtime.ToValue(name), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	return obj
+}
+
+// bufferSource returns the bytes of an ArrayBuffer, a typed array or a
+// DataView. The bytes are shared with the JS object.
+func bufferSource(runtime *goja.Runtime, v goja.Value) ([]byte, bool) {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil, false
+	}
+	if buf, ok := obj.Export().(goja.ArrayBuffer); ok {
+		return buf.Bytes(), true
+	}
+	buffer, offsetValue, lengthValue := obj.Get("buffer"), obj.Get("byteOffset"), obj.Get("byteLength")
+	if buffer == nil || offsetValue == nil || lengthValue == nil {
+		return nil, false
+	}
+	buf, ok := buffer.Export().(goja.ArrayBuffer)
+	if !ok {
+		return nil, false
+	}
+	offset, length := offsetValue.ToInteger(), lengthValue.ToInteger()
+	data := buf.Bytes()
+	if offset < 0 || length < 0 || offset+length > int64(len(data)) {
+		return nil, false
+	}
+	return data[offset : offset+length], true
+}
+
+// newUint8Array returns a Uint8Array over data.
+func newUint8Array(runtime *goja.Runtime, data []byte) goja.Value {
+	arr, err := builtinConstructor(runtime, "Uint8Array")(nil, runtime.ToValue(runtime.NewArrayBuffer(data)))
+	if err != nil {
+		panic(err)
+	}
+	return arr
+}
@@ -0,0 +1,28 @@
+package polyfill
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/require"
+)
+
+func newRuntime(t *testing.T, installs ...func(runtime *goja.Runtime) error) *goja.Runtime {
+	vm := goja.New()
+	for _, install := range installs {
+		require.NoError(t, install(vm))
+	}
+	return vm
+}
+
+func run(t *testing.T, vm *goja.Runtime, src string) goja.Value {
+	v, err := vm.RunString(src)
+	require.NoError(t, err, src)
+	return v
+}
+
+func TestInstallIndividually(t *testing.T) {
+	vm := newRuntime(t, Base64)
+	// 只安装需要的 API
+	require.Equal(t, "function undefined undefined", run(t, vm, `[typeof btoa, typeof URL, typeof TextEncoder].join(" ")`).String())
+}
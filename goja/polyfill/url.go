@@ -0,0 +1,391 @@
+package polyfill
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// URL sets URL and URLSearchParams, parsing URLs with net/url.
+func URL(runtime *goja.Runtime) error {
+	ctor := runtime.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		u, err := parseURL(call.Argument(0), call.Argument(1))
+		if err != nil {
+			panic(runtime.NewTypeError("Invalid URL: " + call.Argument(0).String()))
+		}
+		newURL(runtime, call.This, u)
+		return nil
+	}).(*goja.Object)
+	method(runtime, ctor, "canParse", func(call goja.FunctionCall) goja.Value {
+		_, err := parseURL(call.Argument(0), call.Argument(1))
+		return runtime.ToValue(err == nil)
+	})
+
+	return setGlobals(runtime, map[string]any{
+		"URL": ctor,
+		"URLSearchParams": func(call goja.ConstructorCall) *goja.Object {
+			p := &searchParams{}
+			p.init(runtime, call.Argument(0))
+			newSearchParams(runtime, call.This, p)
+			return nil
+		},
+	})
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443", "ftp": "21"}
+
+// parseURL parses input, relative to base when base is not undefined. The
+// result is absolute.
+func parseURL(input, base goja.Value) (*url.URL, error) {
+	u, err := url.Parse(input.String())
+	if err != nil {
+		return nil, err
+	}
+	if !goja.IsUndefined(base) {
+		b, err := parseURL(base, goja.Undefined())
+		if err != nil {
+			return nil, err
+		}
+		u = b.ResolveReference(u)
+	}
+	if u.Scheme == "" {
+		return nil, errors.New("missing scheme")
+	}
+	normalizeURL(u)
+	return u, nil
+}
+
+func normalizeURL(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if _, special := defaultPorts[u.Scheme]; special && u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+}
+
+func newURL(runtime *goja.Runtime, obj *goja.Object, u *url.URL) {
+	params := &searchParams{}
+	params.list = parseQuery(u.RawQuery)
+	params.update = func() {
+		u.RawQuery = params.String()
+		u.ForceQuery = false
+	}
+
+	str := func(get func() string) func() goja.Value {
+		return func() goja.Value { return runtime.ToValue(get()) }
+	}
+	set := func(update func(v string)) func(goja.Value) {
+		return func(v goja.Value) {
+			update(v.String())
+			normalizeURL(u)
+		}
+	}
+	href := func() string { return u.String() }
+
+	accessor(runtime, obj, "href", str(href), func(v goja.Value) {
+		parsed, err := parseURL(v, goja.Undefined())
+		if err != nil {
+			panic(runtime.NewTypeError("Invalid URL: " + v.String()))
+		}
+		*u = *parsed
+		params.list = parseQuery(u.RawQuery)
+	})
+	accessor(runtime, obj, "origin", str(func() string {
+		if _, special := defaultPorts[u.Scheme]; !special || u.Host == "" {
+			return "null"
+		}
+		return u.Scheme + "://" + u.Host
+	}), nil)
+	accessor(runtime, obj, "protocol", str(func() string { return u.Scheme + ":" }), set(func(v string) {
+		u.Scheme = strings.TrimSuffix(v, ":")
+	}))
+	accessor(runtime, obj, "username", str(func() string { return u.User.Username() }), set(func(v string) {
+		if password, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(v, password)
+		} else {
+			u.User = url.User(v)
+		}
+	}))
+	accessor(runtime, obj, "password", str(func() string {
+		password, _ := u.User.Password()
+		return password
+	}), set(func(v string) {
+		u.User = url.UserPassword(u.User.Username(), v)
+	}))
+	accessor(runtime, obj, "host", str(func() string { return u.Host }), set(func(v string) { u.Host = v }))
+	accessor(runtime, obj, "hostname", str(u.Hostname), set(func(v string) {
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(v, port)
+		} else {
+			u.Host = v
+		}
+	}))
+	accessor(runtime, obj, "port", str(u.Port), set(func(v string) {
+		if v == "" {
+			u.Host = u.Hostname()
+		} else {
+			u.Host = net.JoinHostPort(u.Hostname(), v)
+		}
+	}))
+	accessor(runtime, obj, "pathname", str(func() string {
+		if u.Opaque != "" {
+			return u.Opaque
+		}
+		return u.EscapedPath()
+	}), set(func(v string) {
+		if !strings.HasPrefix(v, "/") {
+			v = "/" + v
+		}
+		if p, err := url.Parse(v); err == nil {
+			u.Path, u.RawPath = p.Path, p.RawPath
+		}
+	}))
+	accessor(runtime, obj, "search", str(func() string {
+		if u.RawQuery == "" {
+			return ""
+		}
+		return "?" + u.RawQuery
+	}), set(func(v string) {
+		u.RawQuery = strings.TrimPrefix(v, "?")
+		u.ForceQuery = false
+		params.list = parseQuery(u.RawQuery)
+	}))
+	accessor(runtime, obj, "hash", str(func() string {
+		if u.Fragment == "" {
+			return ""
+		}
+		return "#" + u.EscapedFragment()
+	}), set(func(v string) {
+		u.Fragment, u.RawFragment = strings.TrimPrefix(v, "#"), ""
+	}))
+
+	searchParamsObj := runtime.NewObject()
+	newSearchParams(runtime, searchParamsObj, params)
+	accessor(runtime, obj, "searchParams", func() goja.Value { return searchParamsObj }, nil)
+
+	method(runtime, obj, "toString", func(goja.FunctionCall) goja.Value { return runtime.ToValue(href()) })
+	method(runtime, obj, "toJSON", func(goja.FunctionCall) goja.Value { return runtime.ToValue(href()) })
+	wrap(runtime, obj, u)
+}
+
+// searchParams is the list of name value pairs of a URLSearchParams.
+type searchParams struct {
+	list [][2]string
+	// update writes the list back to the URL it belongs to, if any
+	update func()
+}
+
+func (p *searchParams) changed() {
+	if p.update != nil {
+		p.update()
+	}
+}
+
+// init fills the list from the argument of the URLSearchParams constructor:
+// a query string, a record or an iterable of pairs.
+func (p *searchParams) init(runtime *goja.Runtime, v goja.Value) {
+	obj, ok := v.(*goja.Object)
+	switch {
+	case v == nil || goja.IsUndefined(v) || goja.IsNull(v):
+	case !ok:
+		p.list = parseQuery(strings.TrimPrefix(v.String(), "?"))
+	case obj.GetSymbol(goja.SymIterator) != nil && !goja.IsUndefined(obj.GetSymbol(goja.SymIterator)):
+		runtime.ForOf(obj, func(item goja.Value) bool {
+			var pair []string
+			if err := runtime.ExportTo(item, &pair); err != nil {
+				panic(runtime.NewTypeError("URLSearchParams: argument 1 must be a sequence of pairs"))
+			}
+			if len(pair) != 2 {
+				panic(runtime.NewTypeError("URLSearchParams: each pair must hold a name and a value"))
+			}
+			p.list = append(p.list, [2]string{pair[0], pair[1]})
+			return true
+		})
+	default:
+		for _, key := range obj.Keys() {
+			p.list = append(p.list, [2]string{key, obj.Get(key).String()})
+		}
+	}
+}
+
+func (p *searchParams) String() string {
+	var b strings.Builder
+	for i, pair := range p.list {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(formEncode(pair[0]))
+		b.WriteByte('=')
+		b.WriteString(formEncode(pair[1]))
+	}
+	return b.String()
+}
+
+func newSearchParams(runtime *goja.Runtime, obj *goja.Object, p *searchParams) {
+	name := func(call goja.FunctionCall, fn string, n int) string {
+		if len(call.Arguments) < n {
+			panic(runtime.NewTypeError("URLSearchParams." + fn + ": " + plural(n) + " required"))
+		}
+		return call.Argument(0).String()
+	}
+	// matches reports whether pair matches the name, and the value when given
+	matches := func(call goja.FunctionCall, pair [2]string, name string) bool {
+		if pair[0] != name {
+			return false
+		}
+		return goja.IsUndefined(call.Argument(1)) || pair[1] == call.Argument(1).String()
+	}
+
+	method(runtime, obj, "append", func(call goja.FunctionCall) goja.Value {
+		p.list = append(p.list, [2]string{name(call, "append", 2), call.Argument(1).String()})
+		p.changed()
+		return goja.Undefined()
+	})
+	method(runtime, obj, "delete", func(call goja.FunctionCall) goja.Value {
+		n := name(call, "delete", 1)
+		kept := p.list[:0]
+		for _, pair := range p.list {
+			if !matches(call, pair, n) {
+				kept = append(kept, pair)
+			}
+		}
+		p.list = kept
+		p.changed()
+		return goja.Undefined()
+	})
+	method(runtime, obj, "get", func(call goja.FunctionCall) goja.Value {
+		n := name(call, "get", 1)
+		for _, pair := range p.list {
+			if pair[0] == n {
+				return runtime.ToValue(pair[1])
+			}
+		}
+		return goja.Null()
+	})
+	method(runtime, obj, "getAll", func(call goja.FunctionCall) goja.Value {
+		n := name(call, "getAll", 1)
+		values := []any{}
+		for _, pair := range p.list {
+			if pair[0] == n {
+				values = append(values, pair[1])
+			}
+		}
+		return runtime.NewArray(values...)
+	})
+	method(runtime, obj, "has", func(call goja.FunctionCall) goja.Value {
+		n := name(call, "has", 1)
+		for _, pair := range p.list {
+			if matches(call, pair, n) {
+				return runtime.ToValue(true)
+			}
+		}
+		return runtime.ToValue(false)
+	})
+	method(runtime, obj, "set", func(call goja.FunctionCall) goja.Value {
+		n, value := name(call, "set", 2), call.Argument(1).String()
+		var list [][2]string
+		found := false
+		for _, pair := range p.list {
+			if pair[0] != n {
+				list = append(list, pair)
+			} else if !found {
+				list = append(list, [2]string{n, value})
+				found = true
+			}
+		}
+		if !found {
+			list = append(list, [2]string{n, value})
+		}
+		p.list = list
+		p.changed()
+		return goja.Undefined()
+	})
+	method(runtime, obj, "sort", func(goja.FunctionCall) goja.Value {
+		sort.SliceStable(p.list, func(i, j int) bool { return p.list[i][0] < p.list[j][0] })
+		p.changed()
+		return goja.Undefined()
+	})
+	pairMethods(runtime, obj, "URLSearchParams", func() [][2]string { return p.list })
+	accessor(runtime, obj, "size", func() goja.Value { return runtime.ToValue(len(p.list)) }, nil)
+	method(runtime, obj, "toString", func(goja.FunctionCall) goja.Value { return runtime.ToValue(p.String()) })
+	wrap(runtime, obj, p)
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(n) + " arguments"
+}
+
+// parseQuery parses an application/x-www-form-urlencoded string, keeping
+// the order of the pairs and the invalid escapes.
+func parseQuery(query string) [][2]string {
+	var list [][2]string
+	for _, part := range strings.Split(query, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		list = append(list, [2]string{formDecode(name), formDecode(value)})
+	}
+	return list
+}
+
+func formDecode(s string) string {
+	s = strings.ReplaceAll(s, "+", " ")
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return decodeUTF8([]byte(b.String()))
+}
+
+func formEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ':
+			b.WriteByte('+')
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("*-._", c) >= 0:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	default:
+		return c - 'a' + 10
+	}
+}
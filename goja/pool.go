@@ -0,0 +1,204 @@
+package goja
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Setup preloads a fresh runtime, typically by running the base scripts
+	// and setting the host bindings shared by every run.
+	Setup func(runtime *goja.Runtime) error
+	// MaxRuns discards a runtime after it served that many runs, 0 means no
+	// limit.
+	MaxRuns int
+	// MaxIdle bounds the idle runtimes kept for reuse, 0 means no limit.
+	MaxIdle int
+	// Isolate snapshots the global state of a runtime after Setup and
+	// restores it on Put, so that runs do not see the globals of the previous
+	// ones. See Isolation for what is restored, scripts declaring top level
+	// let, const or class should be compiled with CompileIsolated. A runtime
+	// that cannot be restored is discarded.
+	Isolate bool
+}
+
+// PoolStats is a snapshot of the counters of a Pool.
+type PoolStats struct {
+	// Created is the number of runtimes created and set up.
+	Created uint64
+	// Reused is the number of checkouts served by an idle runtime.
+	Reused uint64
+	// Discarded is the number of runtimes dropped instead of returned to the
+	// pool.
+	Discarded uint64
+	// Idle is the number of runtimes waiting in the pool.
+	Idle int
+	// InUse is the number of runtimes checked out.
+	InUse int
+}
+
+// Pool hands out preloaded runtimes so that runs skip goja.New and the
+// base scripts. A runtime is used by one goroutine at a time, a Pool is safe
+// for concurrent use.
+type Pool struct {
+	opts PoolOptions
+
+	mu    sync.Mutex
+	idle  []*goja.Runtime
+	live  map[*goja.Runtime]*poolEntry
+	stats PoolStats
+}
+
+type poolEntry struct {
+	runs      int
+	checkout  bool
+	isolation *Isolation // nil unless Isolate
+}
+
+// NewPool returns an empty Pool, runtimes are created on demand.
+func NewPool(opts PoolOptions) *Pool {
+	return &Pool{
+		opts: opts,
+		live: map[*goja.Runtime]*poolEntry{},
+	}
+}
+
+// Get checks out a runtime with a cleared interrupt flag. It must be
+// returned with Put.
+func (p *Pool) Get() (*goja.Runtime, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		rt := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.live[rt].checkout = true
+		p.stats.Reused++
+		p.stats.InUse++
+		p.mu.Unlock()
+		rt.ClearInterrupt()
+		return rt, nil
+	}
+	p.mu.Unlock()
+
+	rt := goja.New()
+	if p.opts.Setup != nil {
+		if err := p.opts.Setup(rt); err != nil {
+			return nil, errors.Wrap(err, "failed to set up runtime")
+		}
+	}
+	entry := &poolEntry{checkout: true}
+	if p.opts.Isolate {
+		iso, err := NewIsolation(rt)
+		if err != nil {
+			return nil, err
+		}
+		entry.isolation = iso
+	}
+	p.mu.Lock()
+	p.live[rt] = entry
+	p.stats.Created++
+	p.stats.InUse++
+	p.mu.Unlock()
+	return rt, nil
+}
+
+// Put returns a runtime checked out by Get, along with the error of its
+// last run. The runtime is discarded when it reached MaxRuns, or when err
+// shows the run may have stopped in the middle of mutating the runtime: an
+// interrupt, a Go panic or any error other than a JS exception.
+func (p *Pool) Put(rt *goja.Runtime, err error) {
+	if reusable(err) && p.opts.Isolate {
+		if iso := p.isolation(rt); iso != nil {
+			if rerr := iso.Restore(); rerr != nil {
+				err = rerr
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.live[rt]
+	if !ok || !entry.checkout {
+		return // not ours, or put twice
+	}
+	entry.checkout = false
+	entry.runs++
+	p.stats.InUse--
+
+	if !reusable(err) ||
+		(p.opts.MaxRuns > 0 && entry.runs >= p.opts.MaxRuns) ||
+		(p.opts.MaxIdle > 0 && len(p.idle) >= p.opts.MaxIdle) {
+		delete(p.live, rt)
+		p.stats.Discarded++
+		return
+	}
+	p.idle = append(p.idle, rt)
+}
+
+// Run checks out a runtime, runs f with WrapRun and returns the runtime.
+// The value returned by f is exported within the run, so that ctx
+// interrupts its getters, and before the runtime goes back to the pool,
+// Result.Value is left nil since the runtime may already serve another run.
+func (p *Pool) Run(ctx context.Context, f func(runtime *goja.Runtime) (goja.Value, error)) (value any, result Result, err error) {
+	rt, err := p.Get()
+	if err != nil {
+		return nil, result, err
+	}
+	result, err = WrapRun(ctx, rt, func(runtime *goja.Runtime) (goja.Value, error) {
+		v, err := f(runtime)
+		if err != nil || v == nil {
+			return v, err
+		}
+		// exporting runs the getters of v, ctx still interrupts them here
+		return v, guard(runtime, func() {
+			value = v.Export()
+		})
+	})
+	if err != nil {
+		value = nil
+	}
+	result.Value = nil
+	p.Put(rt, err)
+	return value, result, err
+}
+
+func (p *Pool) isolation(rt *goja.Runtime) *Isolation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.live[rt]; ok && entry.checkout {
+		return entry.isolation
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the pool counters.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Idle = len(p.idle)
+	return stats
+}
+
+func reusable(err error) bool {
+	if err == nil {
+		return true
+	}
+	var (
+		interrupted *InterruptedError
+		panicErr    *PanicError
+		exception   *ExceptionError
+	)
+	switch {
+	case errors.As(err, &interrupted), errors.As(err, &panicErr):
+		return false
+	case errors.As(err, &exception):
+		return true
+	}
+	// WrapRun refuses to start once the context is done
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
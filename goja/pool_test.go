@@ -0,0 +1,158 @@
+package goja
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(opts PoolOptions) *Pool {
+	opts.Setup = func(runtime *goja.Runtime) error {
+		if err := runtime.Set("double", func(v int) int { return v * 2 }); err != nil {
+			return err
+		}
+		_, err := runtime.RunString(`function quadruple(v) { return double(double(v)); }`)
+		return err
+	}
+	return NewPool(opts)
+}
+
+func TestPoolReuse(t *testing.T) {
+	pool := newTestPool(PoolOptions{})
+	for i := 0; i < 3; i++ {
+		value, result, err := pool.Run(context.Background(), runString(`quadruple(2)`))
+		require.NoError(t, err)
+		assert.Equal(t, int64(8), value)
+		assert.Nil(t, result.Value)
+	}
+	assert.Equal(t, PoolStats{Created: 1, Reused: 2, Idle: 1}, pool.Stats())
+
+	// JS 异常不影响复用
+	_, _, err := pool.Run(context.Background(), runString(`throw new Error("boom")`))
+	assert.ErrorAs(t, err, new(*ExceptionError))
+	assert.Equal(t, PoolStats{Created: 1, Reused: 3, Idle: 1}, pool.Stats())
+}
+
+func TestPoolMaxRuns(t *testing.T) {
+	pool := newTestPool(PoolOptions{MaxRuns: 2})
+	for i := 0; i < 5; i++ {
+		_, _, err := pool.Run(context.Background(), runString(`quadruple(1)`))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, PoolStats{Created: 3, Reused: 2, Discarded: 2, Idle: 1}, pool.Stats())
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+	pool := newTestPool(PoolOptions{MaxIdle: 1})
+	rt1, err := pool.Get()
+	require.NoError(t, err)
+	rt2, err := pool.Get()
+	require.NoError(t, err)
+	assert.Equal(t, PoolStats{Created: 2, InUse: 2}, pool.Stats())
+
+	pool.Put(rt1, nil)
+	pool.Put(rt2, nil)
+	pool.Put(rt2, nil) // 重复 Put 被忽略
+	assert.Equal(t, PoolStats{Created: 2, Discarded: 1, Idle: 1}, pool.Stats())
+}
+
+func TestPoolDiscardsInterrupted(t *testing.T) {
+	pool := newTestPool(PoolOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, result, err := pool.Run(ctx, runString(loopScript))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+	assert.Equal(t, PoolStats{Created: 1, Discarded: 1}, pool.Stats())
+
+	// ctx 已经结束时并没有执行，不必丢弃
+	_, _, err = pool.Run(ctx, runString(`1`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, PoolStats{Created: 2, Discarded: 1, Idle: 1}, pool.Stats())
+}
+
+func TestPoolExportInterrupted(t *testing.T) {
+	// 导出结果时执行的 getter 依然会被 ctx 中断
+	pool := newTestPool(PoolOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	value, result, err := pool.Run(ctx, runString(`({get x() { for (;;) {} }})`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+	assert.Nil(t, value)
+	assert.Equal(t, PoolStats{Created: 1, Discarded: 1}, pool.Stats())
+
+	// getter 抛出的异常作为 ExceptionError 返回
+	_, _, err = pool.Run(context.Background(), runString(`({get x() { throw new RangeError("boom") }})`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "RangeError", exception.Name)
+}
+
+func TestPoolDiscardsPanicked(t *testing.T) {
+	pool := NewPool(PoolOptions{Setup: func(runtime *goja.Runtime) error {
+		return runtime.Set("explode", func() { panic("boom") })
+	}})
+	_, _, err := pool.Run(context.Background(), runString(`explode()`))
+	assert.ErrorAs(t, err, new(*PanicError))
+	assert.Equal(t, PoolStats{Created: 1, Discarded: 1}, pool.Stats())
+}
+
+func TestPoolClearsInterrupt(t *testing.T) {
+	pool := newTestPool(PoolOptions{})
+	rt, err := pool.Get()
+	require.NoError(t, err)
+	// 参考 TestInterrupt，运行之前的 Interrupt 会残留标记
+	rt.Interrupt("stale")
+	pool.Put(rt, nil)
+
+	rt, err = pool.Get()
+	require.NoError(t, err)
+	val, err := rt.RunString(`quadruple(3)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), val.Export())
+	pool.Put(rt, nil)
+}
+
+func TestPoolSetupError(t *testing.T) {
+	pool := NewPool(PoolOptions{Setup: func(runtime *goja.Runtime) error {
+		_, err := runtime.RunString(`syntax error`)
+		return err
+	}})
+	_, _, err := pool.Run(context.Background(), runString(`1`))
+	assert.ErrorContains(t, err, "failed to set up runtime")
+	assert.Equal(t, PoolStats{}, pool.Stats())
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	pool := newTestPool(PoolOptions{MaxRuns: 10})
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, _, err := pool.Run(context.Background(), func(runtime *goja.Runtime) (goja.Value, error) {
+				return runtime.RunString(`quadruple(` + string(rune('0'+i%10)) + `)`)
+			})
+			if err == nil && value != int64(i%10*4) {
+				err = errors.Errorf("unexpected value %v for %d", value, i)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	stats := pool.Stats()
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, uint64(100), stats.Created+stats.Reused)
+}
@@ -0,0 +1,299 @@
+package goja
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// Result is the outcome of WrapRun, it is filled in even when WrapRun
+// returns an error.
+type Result struct {
+	// Value is the value returned by f, nil on error.
+	Value goja.Value
+	// Interrupted reports whether WrapRun interrupted the runtime because ctx
+	// was done. The interrupt flag is cleared again before WrapRun returns.
+	Interrupted bool
+	// Duration is the time spent in f.
+	Duration time.Duration
+}
+
+// InterruptedError reports a run stopped by goja.Runtime.Interrupt. When
+// WrapRun interrupted the run because its context was done, the error matches
+// context.Canceled or context.DeadlineExceeded with errors.Is.
+type InterruptedError struct {
+	// Value is the value passed to Interrupt, or the context error.
+	Value any
+	// Context reports whether WrapRun interrupted the run for its context.
+	Context bool
+	// Err is the error returned by goja.
+	Err *goja.InterruptedError
+}
+
+func (e *InterruptedError) Error() string {
+	if e.Context {
+		return fmt.Sprintf("goja: interrupted: %v", e.Value)
+	}
+	return fmt.Sprintf("goja: interrupted by %v", e.Value)
+}
+
+func (e *InterruptedError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if err, ok := e.Value.(error); ok {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// StackFrame is a frame of a JS stack trace.
+type StackFrame struct {
+	// Func is the function name, empty for the top level code.
+	Func string
+	// File is the name of the script, empty for native functions.
+	File   string
+	Line   int
+	Column int
+}
+
+func (f StackFrame) String() string {
+	if f.File == "" && f.Line == 0 {
+		return fmt.Sprintf("%s (native)", f.Func)
+	}
+	pos := fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	if f.Func == "" {
+		return pos
+	}
+	return fmt.Sprintf("%s (%s)", f.Func, pos)
+}
+
+// ExceptionError reports a JS exception that escaped the script, or the
+// rejection of the promise it returned. Errors returned by Go host functions
+// stay reachable through errors.As.
+type ExceptionError struct {
+	// Name is the name of the thrown Error, such as "TypeError", empty when
+	// a non Error value was thrown.
+	Name string
+	// Message is the message of the thrown Error, or the thrown value
+	// converted to a string.
+	Message string
+	// File, Line and Column locate where the exception was thrown.
+	File   string
+	Line   int
+	Column int
+	// Stack is the JS stack, innermost frame first.
+	Stack []StackFrame
+	// Err is the error returned by goja, nil for a rejected promise.
+	Err *goja.Exception
+	// cause is the Go error a promise was rejected with, or the
+	// *goja.CompilerSyntaxError of a script that failed to compile.
+	cause error
+}
+
+func (e *ExceptionError) Error() string {
+	msg := e.Message
+	if e.Name != "" {
+		msg = e.Name + ": " + msg
+	}
+	if e.File == "" && e.Line == 0 {
+		return "goja: " + msg
+	}
+	return fmt.Sprintf("goja: %s at %s:%d:%d", msg, e.File, e.Line, e.Column)
+}
+
+func (e *ExceptionError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
+// PanicError reports a Go panic raised by a host function during the run.
+// The runtime should not be reused after it.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the Go stack of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("goja: panic: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// contextInterrupt is the value WrapRun interrupts the runtime with, it
+// tells our interrupts from the ones of the caller.
+type contextInterrupt struct {
+	err error
+}
+
+// WrapRun runs f against runtime and interrupts it when ctx is done.
+//
+// The interrupt flag of runtime is cleared before f runs and again after an
+// interrupt raced with the end of f, so the runtime stays usable. Errors are
+// classified as *InterruptedError, *ExceptionError (syntax errors included)
+// or *PanicError, other errors returned by f are wrapped.
+func WrapRun(
+	ctx context.Context,
+	runtime *goja.Runtime,
+	f func(runtime *goja.Runtime) (goja.Value, error),
+) (result Result, err error) {
+	if ctx.Err() != nil {
+		return result, errors.Wrap(ctx.Err(), "context already done")
+	}
+
+	// 直接在 f 运行之前保证之前的 Interrupted 标记被清除，此方法内部其实只是执行了一个 atomic.Store 所以成本其实很低
+	runtime.ClearInterrupt()
+
+	interruptDone := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(interruptDone)
+		runtime.Interrupt(contextInterrupt{err: context.Cause(ctx)})
+	})
+
+	start := time.Now()
+	value, err := call(runtime, f)
+	result.Duration = time.Since(start)
+	if err != nil {
+		// describing the thrown value may run getters of the script, it is
+		// done while ctx can still interrupt them
+		err = classify(runtime, err)
+	}
+
+	if !stop() {
+		// 中断可能发生在 f 结束之后，此时标记不会被重置，需要等其设置完毕后主动清除
+		<-interruptDone
+		runtime.ClearInterrupt()
+		result.Interrupted = true
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Value = value
+	return result, nil
+}
+
+func call(runtime *goja.Runtime, f func(runtime *goja.Runtime) (goja.Value, error)) (value goja.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f(runtime)
+}
+
+func classify(runtime *goja.Runtime, err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if ci, ok := interrupted.Value().(contextInterrupt); ok {
+			return &InterruptedError{Value: ci.err, Context: true, Err: interrupted}
+		}
+		return &InterruptedError{Value: interrupted.Value(), Err: interrupted}
+	}
+
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return newExceptionError(runtime, exception)
+	}
+
+	var syntaxErr *goja.CompilerSyntaxError
+	if errors.As(err, &syntaxErr) {
+		return newSyntaxError(syntaxErr)
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return err
+	}
+	return errors.Wrap(err, "failed to run")
+}
+
+func newExceptionError(runtime *goja.Runtime, exception *goja.Exception) *ExceptionError {
+	e := &ExceptionError{Err: exception}
+	e.describe(runtime, exception.Value())
+
+	for _, frame := range exception.Stack() {
+		pos := frame.Position()
+		sf := StackFrame{Line: pos.Line, Column: pos.Column}
+		if name := frame.FuncName(); name != "<anonymous>" && name != "<native>" {
+			sf.Func = name
+		}
+		// the position names the original source of a program compiled
+		// with a source map
+		if frame.SrcName() != "<native>" {
+			sf.File = pos.Filename
+		}
+		e.Stack = append(e.Stack, sf)
+	}
+	for _, sf := range e.Stack {
+		if sf.File != "" || sf.Line != 0 {
+			e.File, e.Line, e.Column = sf.File, sf.Line, sf.Column
+			break
+		}
+	}
+	return e
+}
+
+// newSyntaxError reports a script f failed to compile as a SyntaxError
+// exception, like the ones of RunString.
+func newSyntaxError(err *goja.CompilerSyntaxError) *ExceptionError {
+	e := &ExceptionError{Name: "SyntaxError", Message: err.Message, cause: err}
+	if err.File != nil {
+		pos := err.File.Position(err.Offset)
+		e.File, e.Line, e.Column = pos.Filename, pos.Line, pos.Column
+	}
+	return e
+}
+
+// describe fills in the name and message of the thrown value v. The getters
+// and toString of the script it may call are guarded, when they throw or are
+// interrupted the message falls back to the class of v.
+func (e *ExceptionError) describe(runtime *goja.Runtime, v goja.Value) {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if v != nil {
+			e.Message = v.String()
+		}
+		return
+	}
+	err := guard(runtime, func() {
+		if name := obj.Get("name"); name != nil && !goja.IsUndefined(name) {
+			e.Name = name.String()
+		}
+		if msg := obj.Get("message"); msg != nil && !goja.IsUndefined(msg) {
+			e.Message = msg.String()
+		} else {
+			e.Message = obj.String()
+		}
+	})
+	if err != nil && e.Message == "" {
+		e.Message = "[object " + obj.ClassName() + "]"
+	}
+}
+
+// guard runs f like a host function called by JS, so that the exceptions
+// thrown by the script code f calls, such as getters, and the interrupts met
+// in it are returned instead of panicking.
+func guard(runtime *goja.Runtime, f func()) error {
+	fn, _ := goja.AssertFunction(runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		f()
+		return goja.Undefined()
+	}))
+	_, err := fn(goja.Undefined())
+	return err
+}
@@ -0,0 +1,199 @@
+package goja
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const loopScript = `
+var i = 0;
+for (;;) {
+	i++;
+}
+`
+
+func runString(src string) func(runtime *goja.Runtime) (goja.Value, error) {
+	return func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunScript("script.js", src)
+	}
+}
+
+func TestWrapRunCanceled(t *testing.T) {
+	vm := goja.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	result, err := WrapRun(ctx, vm, runString(loopScript))
+	assert.True(t, result.Interrupted)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+
+	var interrupted *InterruptedError
+	require.ErrorAs(t, err, &interrupted)
+	assert.True(t, interrupted.Context)
+	// 依然能拿到 goja 原始的错误
+	assert.ErrorAs(t, err, new(*goja.InterruptedError))
+
+	// 已经结束的 ctx 不会执行
+	result, err = WrapRun(ctx, vm, runString("1"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, result.Interrupted)
+}
+
+func TestWrapRunDeadline(t *testing.T) {
+	vm := goja.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	result, err := WrapRun(ctx, vm, runString(loopScript))
+	assert.True(t, result.Interrupted)
+	assert.GreaterOrEqual(t, result.Duration, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWrapRunExplicitInterrupt(t *testing.T) {
+	vm := goja.New()
+	time.AfterFunc(10*time.Millisecond, func() {
+		vm.Interrupt("halt")
+	})
+
+	result, err := WrapRun(context.Background(), vm, runString(loopScript))
+	assert.False(t, result.Interrupted)
+	assert.NotErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+
+	var interrupted *InterruptedError
+	require.ErrorAs(t, err, &interrupted)
+	assert.False(t, interrupted.Context)
+	assert.Equal(t, "halt", interrupted.Value)
+}
+
+func TestWrapRunException(t *testing.T) {
+	vm := goja.New()
+	_, err := WrapRun(context.Background(), vm, runString(`
+function check(v) {
+	if (v > 1) {
+		throw new TypeError("too big: " + v);
+	}
+}
+check(2);
+`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "TypeError", exception.Name)
+	assert.Equal(t, "too big: 2", exception.Message)
+	assert.Equal(t, "script.js", exception.File)
+	assert.Equal(t, 4, exception.Line)
+	assert.Equal(t, 9, exception.Column)
+	require.Len(t, exception.Stack, 2)
+	assert.Equal(t, StackFrame{Func: "check", File: "script.js", Line: 4, Column: 9}, exception.Stack[0])
+	assert.Equal(t, StackFrame{File: "script.js", Line: 7, Column: 6}, exception.Stack[1])
+	assert.Equal(t, "goja: TypeError: too big: 2 at script.js:4:9", err.Error())
+
+	// 抛出非 Error 的值
+	_, err = WrapRun(context.Background(), vm, runString(`throw "oops"`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "", exception.Name)
+	assert.Equal(t, "oops", exception.Message)
+}
+
+func TestWrapRunExceptionGetters(t *testing.T) {
+	vm := goja.New()
+
+	// 描述抛出值时 getter 抛出的异常不会导致 panic
+	_, err := WrapRun(context.Background(), vm, runString(`throw {name: "Custom", get message() { throw new Error("boom") }}`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "Custom", exception.Name)
+	assert.Equal(t, "[object Object]", exception.Message)
+
+	// 死循环的 getter 依然会被 ctx 中断
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := WrapRun(ctx, vm, runString(`throw {get name() { for (;;) {} }}`))
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "", exception.Name)
+	assert.Equal(t, "[object Object]", exception.Message)
+	assert.True(t, result.Interrupted)
+
+	// runtime 依然可用
+	result, err = WrapRun(context.Background(), vm, runString(`1 + 1`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Value.Export())
+}
+
+var errNotFound = errors.New("not found")
+
+type lookupError struct {
+	Key string
+}
+
+func (e *lookupError) Error() string {
+	return "lookup " + e.Key + " failed"
+}
+
+func TestWrapRunHostError(t *testing.T) {
+	vm := goja.New()
+	require.NoError(t, vm.Set("lookup", func(key string) (string, error) {
+		if key == "missing" {
+			return "", errNotFound
+		}
+		return "", &lookupError{Key: key}
+	}))
+
+	_, err := WrapRun(context.Background(), vm, runString(`lookup("missing")`))
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorAs(t, err, new(*ExceptionError))
+
+	_, err = WrapRun(context.Background(), vm, runString(`lookup("x")`))
+	var lookupErr *lookupError
+	require.ErrorAs(t, err, &lookupErr)
+	assert.Equal(t, "x", lookupErr.Key)
+
+	// JS 内可以 catch 到 Go 的错误
+	result, err := WrapRun(context.Background(), vm, runString(`
+try {
+	lookup("missing");
+} catch (e) {
+	e.message
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "not found", result.Value.String())
+}
+
+func TestWrapRunPanic(t *testing.T) {
+	vm := goja.New()
+	require.NoError(t, vm.Set("explode", func() {
+		panic(errNotFound)
+	}))
+
+	_, err := WrapRun(context.Background(), vm, runString(`explode()`))
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, errNotFound, panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestWrapRunSyntaxError(t *testing.T) {
+	vm := goja.New()
+	_, err := WrapRun(context.Background(), vm, runString(`var =`))
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "SyntaxError", exception.Name)
+	assert.Contains(t, exception.Message, "script.js: Line 1:5 Unexpected token =")
+
+	// 非 goja 的错误会被包装返回
+	_, err = WrapRun(context.Background(), vm, func(runtime *goja.Runtime) (goja.Value, error) {
+		return nil, errNotFound
+	})
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorContains(t, err, "failed to run")
+}
@@ -0,0 +1,150 @@
+package goja
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// Sandbox is the policy applied to runtimes running untrusted scripts.
+//
+// Applying it disables code generation from strings: eval, the Function
+// constructor and the constructors reachable from functions, generators and
+// async functions throw an EvalError. The builtin objects, their prototypes
+// and the hidden intrinsics such as the iterator prototypes are frozen, and
+// the global bindings of the builtins cannot be reassigned. Scripts can still
+// assign toString, valueOf, constructor, name and message to their own
+// objects, these builtin properties are turned into accessors to avoid the
+// override mistake of frozen prototypes.
+//
+// Only the host bindings granted to a runtime are set on it, as read only
+// globals. The deadline of the scripts is enforced by WrapRun as usual.
+type Sandbox struct {
+	// Bindings are the host values scripts may be granted, by global name.
+	Bindings map[string]any
+	// Deterministic makes Date.now and new Date() return Now, and Math.random
+	// the pseudo random sequence generated from Seed, so that a script gives
+	// the same result on every run. The random sequence starts when the
+	// sandbox is applied, a reused runtime continues it.
+	Deterministic bool
+	// Now is the time seen by scripts in deterministic mode, the Unix epoch
+	// when zero.
+	Now time.Time
+	// Seed seeds Math.random in deterministic mode.
+	Seed uint64
+}
+
+// intrinsics are the globals of the builtins locked down by Sandbox.
+var intrinsics = []string{
+	"Object", "Function", "Array", "String", "Number", "BigInt", "RegExp", "Date", "Boolean",
+	"Proxy", "Reflect", "Error", "AggregateError", "TypeError", "ReferenceError", "SyntaxError",
+	"RangeError", "EvalError", "URIError", "GoError", "eval", "Math", "JSON", "ArrayBuffer",
+	"DataView", "Uint8Array", "Uint8ClampedArray", "Int8Array", "Uint16Array", "Int16Array",
+	"Uint32Array", "Int32Array", "Float32Array", "Float64Array", "BigInt64Array", "BigUint64Array",
+	"Symbol", "WeakSet", "WeakMap", "Map", "Set", "Promise", "isNaN", "parseInt", "parseFloat",
+	"isFinite", "decodeURI", "decodeURIComponent", "encodeURI", "encodeURIComponent", "escape",
+	"unescape",
+}
+
+const lockdownScript = `(function (intrinsics) {
+	"use strict";
+	var blocked = function () {
+		throw new EvalError("code generation from strings is not allowed");
+	};
+	[function () {}, function* () {}, async function () {}].forEach(function (fn) {
+		Object.defineProperty(Object.getPrototypeOf(fn), "constructor", {value: blocked});
+	});
+	// keeps instanceof Function working
+	blocked.prototype = Function.prototype;
+	globalThis.Function = blocked;
+	globalThis.eval = blocked;
+
+	function overridable(obj, name) {
+		var desc = Object.getOwnPropertyDescriptor(obj, name);
+		if (!desc || !("value" in desc)) {
+			return;
+		}
+		var value = desc.value;
+		Object.defineProperty(obj, name, {
+			get: function () { return value; },
+			set: function (v) {
+				if (this === obj) {
+					throw new TypeError("Cannot assign to read only property '" + name + "' of a builtin");
+				}
+				Object.defineProperty(this, name, {value: v, writable: true, enumerable: true, configurable: true});
+			},
+			enumerable: desc.enumerable,
+		});
+	}
+	["toString", "valueOf", "toLocaleString", "hasOwnProperty", "constructor"].forEach(function (name) {
+		overridable(Object.prototype, name);
+	});
+	["toString", "constructor", "name", "message"].forEach(function (name) {
+		overridable(Error.prototype, name);
+	});
+
+	var seen = new WeakSet();
+	function harden(v) {
+		if (v === null || (typeof v !== "object" && typeof v !== "function") || seen.has(v)) {
+			return;
+		}
+		seen.add(v);
+		Object.freeze(v);
+		harden(Object.getPrototypeOf(v));
+		Reflect.ownKeys(v).forEach(function (key) {
+			var desc = Object.getOwnPropertyDescriptor(v, key);
+			harden(desc.value);
+			harden(desc.get);
+			harden(desc.set);
+		});
+	}
+	intrinsics.forEach(function (name) {
+		harden(globalThis[name]);
+		Object.defineProperty(globalThis, name, {writable: false, configurable: false});
+	});
+	// the intrinsics only reachable from instances
+	harden([
+		function* () {}, (function* () {})(), async function () {}, Promise.resolve(),
+		[][Symbol.iterator](), new Map().entries(), new Set().values(), ""[Symbol.iterator](),
+		/x/[Symbol.matchAll]("x"), new Uint8Array(0),
+	]);
+})`
+
+// Apply locks down a fresh runtime, before any script runs on it, and sets
+// the granted bindings. Granting a binding missing from Bindings is an error.
+// Apply fits PoolOptions.Setup, a pool holding runtimes of one set of grants.
+func (s *Sandbox) Apply(runtime *goja.Runtime, grants ...string) error {
+	for _, name := range grants {
+		if _, ok := s.Bindings[name]; !ok {
+			return errors.Errorf("goja: no binding %q to grant", name)
+		}
+	}
+
+	if s.Deterministic {
+		now := s.Now
+		if now.IsZero() {
+			now = time.Unix(0, 0)
+		}
+		runtime.SetTimeSource(func() time.Time { return now })
+		runtime.SetRandSource(rand.New(rand.NewPCG(s.Seed, s.Seed)).Float64)
+	}
+
+	lockdown, err := runtime.RunString(lockdownScript)
+	if err != nil {
+		return errors.Wrap(err, "failed to compile the lockdown")
+	}
+	fn, _ := goja.AssertFunction(lockdown)
+	if _, err := fn(goja.Undefined(), runtime.ToValue(intrinsics)); err != nil {
+		return errors.Wrap(err, "failed to lock down the runtime")
+	}
+
+	global := runtime.GlobalObject()
+	for _, name := range grants {
+		if err := global.DefineDataProperty(name, runtime.ToValue(s.Bindings[name]), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE); err != nil {
+			return errors.Wrapf(err, "failed to set %s", name)
+		}
+	}
+	return nil
+}
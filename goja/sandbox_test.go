@@ -0,0 +1,149 @@
+package goja
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSandbox(t *testing.T, sandbox *Sandbox, grants ...string) *goja.Runtime {
+	vm := goja.New()
+	require.NoError(t, sandbox.Apply(vm, grants...))
+	return vm
+}
+
+func TestSandboxCodeGeneration(t *testing.T) {
+	vm := newTestSandbox(t, &Sandbox{})
+
+	for _, src := range []string{
+		`eval("1 + 1")`,
+		`Function("return 1")()`,
+		`new Function("return 1")()`,
+		`(function () {}).constructor("return 1")()`,
+		`(function () {}).constructor.constructor("return 1")()`,
+		`Object.getPrototypeOf(function* () {}).constructor("yield 1")`,
+		`Object.getPrototypeOf(async function () {}).constructor("return 1")`,
+		`Reflect.construct(Function, ["return 1"])`,
+	} {
+		_, err := WrapRun(context.Background(), vm, runString(src))
+		var exception *ExceptionError
+		if assert.ErrorAs(t, err, &exception, src) {
+			assert.Equal(t, "EvalError", exception.Name, src)
+		}
+	}
+
+	// 禁止的只是从字符串生成代码
+	result, err := WrapRun(context.Background(), vm, runString(`
+var add = function (a, b) { return a + b; };
+[add(1, 2), add instanceof Function, typeof Function, [1, 2, 3].map(function (v) { return v * 2; }).join()]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(3), true, "function", "2,4,6"}, result.Value.Export())
+}
+
+func TestSandboxFrozenBuiltins(t *testing.T) {
+	vm := newTestSandbox(t, &Sandbox{})
+
+	for _, src := range []string{
+		`"use strict"; Array.prototype.map = function () { return "pwned"; }`,
+		`"use strict"; Object.prototype.polluted = true`,
+		`"use strict"; JSON.parse = function () {}`,
+		`"use strict"; Math.random = function () { return 0; }`,
+		`"use strict"; Object.getPrototypeOf([][Symbol.iterator]()).next = function () {}`,
+		`"use strict"; Object.getPrototypeOf(Object.getPrototypeOf((function* () {})())).next = null`,
+		`"use strict"; Object.prototype.toString = function () { return "x"; }`,
+		`Object.defineProperty(Array.prototype, "evil", {value: 1})`,
+		`Object.setPrototypeOf(Array.prototype, null)`,
+		`"use strict"; Array = function () {}`,
+		`"use strict"; delete globalThis.JSON`,
+	} {
+		_, err := WrapRun(context.Background(), vm, runString(src))
+		var exception *ExceptionError
+		if assert.ErrorAs(t, err, &exception, src) {
+			assert.Equal(t, "TypeError", exception.Name, src)
+		}
+	}
+
+	// 非严格模式下赋值静默失败
+	result, err := WrapRun(context.Background(), vm, runString(`Array.prototype.map = null; Array = null; typeof [].map + typeof Array`))
+	require.NoError(t, err)
+	assert.Equal(t, "functionfunction", result.Value.String())
+
+	// 脚本自己的对象依然可以覆盖内置的属性
+	result, err = WrapRun(context.Background(), vm, runString(`
+"use strict";
+function Point(x) { this.x = x; }
+Point.prototype.toString = function () { return "Point(" + this.x + ")"; };
+function MyError(msg) { this.message = msg; }
+MyError.prototype = Object.create(Error.prototype);
+MyError.prototype.name = "MyError";
+MyError.prototype.constructor = MyError;
+var o = {};
+o.valueOf = function () { return 42; };
+class Base { toString() { return "base"; } }
+[String(new Point(1)), String(new MyError("oops")), o + 1, String(new Base()), String({})]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"Point(1)", "MyError: oops", int64(43), "base", "[object Object]"}, result.Value.Export())
+}
+
+func TestSandboxBindings(t *testing.T) {
+	sandbox := &Sandbox{Bindings: map[string]any{
+		"greet":  func(name string) string { return "hello " + name },
+		"secret": func() string { return "s3cr3t" },
+	}}
+
+	vm := newTestSandbox(t, sandbox, "greet")
+	result, err := WrapRun(context.Background(), vm, runString(`[greet("bob"), typeof secret]`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"hello bob", "undefined"}, result.Value.Export())
+
+	// 授予的绑定不能被替换
+	result, err = WrapRun(context.Background(), vm, runString(`greet = function () { return "pwned"; }; greet("bob")`))
+	require.NoError(t, err)
+	assert.Equal(t, "hello bob", result.Value.String())
+
+	err = sandbox.Apply(goja.New(), "missing")
+	assert.ErrorContains(t, err, `no binding "missing"`)
+}
+
+func TestSandboxDeterministic(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	run := func(seed uint64) []any {
+		vm := newTestSandbox(t, &Sandbox{Deterministic: true, Now: now, Seed: seed})
+		result, err := WrapRun(context.Background(), vm, runString(`[Date.now(), new Date().toISOString(), Math.random(), Math.random()]`))
+		require.NoError(t, err)
+		return result.Value.Export().([]any)
+	}
+
+	first := run(1)
+	assert.Equal(t, now.UnixMilli(), first[0])
+	assert.Equal(t, "2024-01-02T03:04:05.000Z", first[1])
+	assert.NotEqual(t, first[2], first[3])
+	assert.Equal(t, first, run(1))
+	assert.NotEqual(t, first[2:], run(2)[2:])
+
+	vm := newTestSandbox(t, &Sandbox{Deterministic: true})
+	result, err := WrapRun(context.Background(), vm, runString(`Date.now()`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Value.Export())
+}
+
+func TestSandboxInterrupt(t *testing.T) {
+	vm := newTestSandbox(t, &Sandbox{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// 沙箱内的死循环依然可以被中断，runtime 之后依然可用
+	result, err := WrapRun(ctx, vm, runString(loopScript))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, result.Interrupted)
+
+	result, err = WrapRun(context.Background(), vm, runString(`1 + 1`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Value.Export())
+}
@@ -0,0 +1,36 @@
+package goja
+
+import (
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"github.com/go-sourcemap/sourcemap"
+	"github.com/pkg/errors"
+)
+
+// CompileWithSourceMap compiles src generated by a transpiler, TypeScript
+// for instance, along with its source map. The positions of the program,
+// in the errors of WrapRun as well as in the stack property of JS errors,
+// refer to the original sources, named relative to name. Positions missing
+// from the map are reported in src.
+//
+// A sourceMappingURL comment in src is ignored, sourceMap replaces it.
+func CompileWithSourceMap(name, src string, sourceMap []byte) (*goja.Program, error) {
+	consumer, err := sourcemap.Parse(name, sourceMap)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the source map of %s", name)
+	}
+	ast, err := goja.Parse(name, src, parser.WithDisableSourceMaps)
+	if err != nil {
+		return nil, err
+	}
+	ast.File.SetSourceMap(consumer)
+	return goja.CompileAST(ast, false)
+}
+
+func compileWithoutSourceMap(name, src string) (*goja.Program, error) {
+	ast, err := goja.Parse(name, src, parser.WithDisableSourceMaps)
+	if err != nil {
+		return nil, err
+	}
+	return goja.CompileAST(ast, false)
+}
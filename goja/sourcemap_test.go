@@ -0,0 +1,101 @@
+package goja
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 由 src/app.ts 编译而来:
+//
+//	interface User {
+//	  name: string;
+//	}
+//
+//	function greet(user: User): string {
+//	  if (!user.name) {
+//	    throw new Error("missing name");
+//	  }
+//	  return "hello " + user.name;
+//	}
+//
+//	greet({} as User);
+const (
+	generatedScript = `function greet(user) {
+    if (!user.name) {
+        throw new Error("missing name");
+    }
+    return "hello " + user.name;
+}
+greet({});
+//# sourceMappingURL=app.js.map
+`
+	generatedSourceMap = `{
+	"version": 3,
+	"file": "app.js",
+	"sources": ["../src/app.ts"],
+	"names": [],
+	"mappings": "AAIA;AACA;AACA,QAAI,MAAM;AACV;AACA;AACA;AAEA,KAAK,CAAC,EAAU,CAAC"
+}`
+)
+
+func TestCompileWithSourceMap(t *testing.T) {
+	program, err := CompileWithSourceMap("dist/app.js", generatedScript, []byte(generatedSourceMap))
+	require.NoError(t, err)
+
+	_, err = WrapRun(context.Background(), goja.New(), func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunProgram(program)
+	})
+	var exception *ExceptionError
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "src/app.ts", exception.File)
+	assert.Equal(t, 7, exception.Line)
+	assert.Equal(t, []StackFrame{
+		{Func: "greet", File: "src/app.ts", Line: 7, Column: exception.Column},
+		{File: "src/app.ts", Line: 12, Column: exception.Stack[1].Column},
+	}, exception.Stack)
+	assert.Contains(t, err.Error(), "at src/app.ts:7:")
+
+	// JS 中的 stack 属性同样指向原始的源码
+	result, err := WrapRun(context.Background(), goja.New(), func(runtime *goja.Runtime) (goja.Value, error) {
+		if _, err := runtime.RunProgram(program); err == nil {
+			return nil, nil
+		}
+		return runtime.RunString(`
+try {
+	greet({});
+} catch (e) {
+	e.stack;
+}
+`)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result.Value.String(), "greet (src/app.ts:7:")
+
+	// 没有 source map 时指向生成的代码，且不会去读取 sourceMappingURL 指向的文件
+	_, err = RunCached(context.Background(), goja.New(), "script.js", generatedScript)
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "script.js", exception.File)
+	assert.Equal(t, 3, exception.Line)
+
+	_, err = CompileWithSourceMap("dist/app.js", generatedScript, []byte(`{"version": 3`))
+	assert.ErrorContains(t, err, "failed to parse the source map of dist/app.js")
+}
+
+func TestProgramCacheSourceMap(t *testing.T) {
+	cache := NewProgramCache(0, 0)
+	p1, err := cache.CompileWithSourceMap("dist/app.js", generatedScript, []byte(generatedSourceMap))
+	require.NoError(t, err)
+	p2, err := cache.CompileWithSourceMap("dist/app.js", generatedScript, []byte(generatedSourceMap))
+	require.NoError(t, err)
+	assert.Same(t, p1, p2)
+
+	// source map 不同则是不同的 program
+	p3, err := cache.Compile("dist/app.js", generatedScript)
+	require.NoError(t, err)
+	assert.NotSame(t, p1, p3)
+	assert.Equal(t, 2*len(generatedScript)+len(generatedSourceMap), cache.Stats().Bytes)
+}
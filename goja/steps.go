@@ -0,0 +1,292 @@
+package goja
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+	"github.com/pkg/errors"
+)
+
+// stepFunc is the global called by metered scripts on every step.
+const stepFunc = "__gojaStep__"
+
+// CompileMetered compiles src so that every loop iteration and function
+// call counts a step against Limits.MaxSteps, which stops a script at the
+// same point on every run whatever the load of the machine. The program
+// must be run with Limiter.Run and MaxSteps set.
+//
+// The steps are counted by calls inserted in the source, the columns of the
+// lines holding them are shifted in the positions of errors, and the source
+// returned by the toString of functions shows them. Sources naming the
+// counter or using with statements, which could shadow it, are rejected.
+// Code generated from strings is not metered, a Sandbox disables it.
+func CompileMetered(name, src string) (*goja.Program, error) {
+	program, err := goja.Parse(name, src, parser.WithDisableSourceMaps)
+	if err != nil {
+		return nil, err
+	}
+	m := &meter{src: src, file: program.File, seen: map[ast.Node]bool{}}
+	m.walk(reflect.ValueOf(program))
+	if m.err != nil {
+		return nil, m.err
+	}
+	return compileWithoutSourceMap(name, m.rewrite())
+}
+
+type insertion struct {
+	offset int
+	text   string
+	// closing texts go before the opening ones inserted at the same offset
+	closing bool
+}
+
+// meter collects the step calls to insert in src.
+type meter struct {
+	src        string
+	file       *file.File
+	seen       map[ast.Node]bool
+	insertions []insertion
+	err        error // the first forbidden construct
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// walk visits the AST nodes reachable from v.
+func (m *meter) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			m.walk(v.Elem())
+		}
+	case reflect.Pointer:
+		if v.IsNil() || !v.CanInterface() {
+			return
+		}
+		if node, ok := v.Interface().(ast.Node); ok {
+			// the declaration lists repeat the declarations of the body
+			if m.seen[node] {
+				return
+			}
+			m.seen[node] = true
+			m.visit(node)
+		}
+		m.walk(v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			m.walk(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type().PkgPath() != nodeType.PkgPath() {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			m.walk(v.Field(i))
+		}
+	}
+}
+
+func (m *meter) visit(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Identifier:
+		if n.Name == stepFunc {
+			m.fail(n.Idx, "%s is reserved for the step counter", stepFunc)
+		}
+	case *ast.WithStatement:
+		m.fail(n.With, "with statements are not allowed in metered scripts")
+	case *ast.ForStatement:
+		m.loopBody(m.headerEnd(n.For, n.Initializer, n.Test, n.Update), n.Body)
+	case *ast.ForInStatement:
+		m.loopBody(m.headerEnd(n.For, n.Source), n.Body)
+	case *ast.ForOfStatement:
+		m.loopBody(m.headerEnd(n.For, n.Source), n.Body)
+	case *ast.WhileStatement:
+		m.loopBody(m.headerEnd(n.While, n.Test), n.Body)
+	case *ast.DoWhileStatement:
+		m.loopBody(offset(n.Do)+len("do"), n.Body)
+	case *ast.FunctionLiteral:
+		m.functionBody(n.Body)
+	case *ast.ArrowFunctionLiteral:
+		switch body := n.Body.(type) {
+		case *ast.BlockStatement:
+			m.functionBody(body)
+		case *ast.ExpressionBody:
+			m.insert(body.Expression.Idx0(), "("+stepFunc+"(), ", false)
+			m.insert(body.Expression.Idx1(), ")", true)
+		}
+	}
+}
+
+// headerEnd returns the offset past the header of the loop starting with the
+// keyword at idx, made of parts. The indexes of the parser leave out the
+// parentheses around expressions, so the body is looked for past the
+// closing parentheses and the semicolons of a for header.
+func (m *meter) headerEnd(idx file.Idx, parts ...ast.Node) int {
+	end := -1
+	for _, part := range parts {
+		if part != nil && offset(part.Idx1()) > end {
+			end = offset(part.Idx1())
+		}
+	}
+	if end < 0 {
+		// for (;;), past its opening parenthesis
+		end = skipSpace(m.src, offset(idx)+len("for")) + 1
+	}
+	for {
+		i := skipSpace(m.src, end)
+		if i >= len(m.src) || (m.src[i] != ')' && m.src[i] != ';') {
+			return end
+		}
+		end = i + 1
+	}
+}
+
+// loopBody wraps the body of a loop, starting past the offset header, in a
+// block counting a step.
+func (m *meter) loopBody(header int, body ast.Statement) {
+	if block, ok := body.(*ast.BlockStatement); ok {
+		m.insert(block.LeftBrace+1, stepFunc+"();", false)
+		return
+	}
+	// the parser leaves the index of if statements unset, the block opens
+	// past the header, or before the semicolon of an empty statement which
+	// the header could take for its own
+	start := header
+	if empty, ok := body.(*ast.EmptyStatement); ok {
+		start = offset(empty.Semicolon)
+	}
+	m.insertions = append(m.insertions, insertion{offset: start, text: "{" + stepFunc + "();"})
+	// the statement ends past its closing parentheses and semicolon
+	end := closeParens(m.src, offset(body.Idx1()))
+	rest := strings.TrimLeft(m.src[end:], " \t")
+	if strings.HasPrefix(rest, ";") {
+		end = len(m.src) - len(rest) + 1
+	}
+	m.insertions = append(m.insertions, insertion{offset: end, text: "}", closing: true})
+}
+
+// functionBody inserts the step after the directives, a "use strict" must
+// stay first.
+func (m *meter) functionBody(body *ast.BlockStatement) {
+	start := offset(body.LeftBrace) + 1
+	if end := prologueEnd(m.src, body.List, start); end > start {
+		m.insertions = append(m.insertions, insertion{offset: end, text: ";" + stepFunc + "();"})
+		return
+	}
+	m.insert(body.LeftBrace+1, stepFunc+"();", false)
+}
+
+func (m *meter) fail(idx file.Idx, format string, args ...any) {
+	if m.err == nil {
+		m.err = errors.Errorf("%s: %s", m.file.Position(offset(idx)), fmt.Sprintf(format, args...))
+	}
+}
+
+func (m *meter) insert(idx file.Idx, text string, closing bool) {
+	m.insertions = append(m.insertions, insertion{offset: offset(idx), text: text, closing: closing})
+}
+
+// offset is the byte offset in the source of an index of the parser, which
+// counts from 1.
+func offset(idx file.Idx) int {
+	return int(idx) - 1
+}
+
+// skipSpace returns the offset of the first token of src at or past i,
+// skipping white space and comments.
+func skipSpace(src string, i int) int {
+	for i < len(src) {
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			n := strings.IndexAny(src[i:], "\n\r\u2028\u2029")
+			if n < 0 {
+				return len(src)
+			}
+			i += n
+		case strings.HasPrefix(src[i:], "/*"):
+			n := strings.Index(src[i+2:], "*/")
+			if n < 0 {
+				return len(src)
+			}
+			i += n + 4
+		default:
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if !unicode.IsSpace(r) && r != '\ufeff' {
+				return i
+			}
+			i += size
+		}
+	}
+	return i
+}
+
+// prologueEnd returns the offset past the directives, such as "use strict",
+// of the statements list starting at the offset from. The directives are
+// the string literals standing alone as statements, a parenthesized one is
+// not. Text inserted past a directive needs a semicolon before it, the
+// directive may rely on automatic semicolon insertion.
+func prologueEnd(src string, list []ast.Statement, from int) int {
+	end := from
+	for _, stmt := range list {
+		expr, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			break
+		}
+		lit, ok := expr.Expression.(*ast.StringLiteral)
+		if !ok || offset(lit.Idx0()) != skipSemicolons(src, end) {
+			break
+		}
+		end = offset(lit.Idx1())
+	}
+	return end
+}
+
+// closeParens returns the offset past the closing parentheses following the
+// offset i, which the indexes of the parser leave out of parenthesized
+// expressions.
+func closeParens(src string, i int) int {
+	for {
+		j := skipSpace(src, i)
+		if j >= len(src) || src[j] != ')' {
+			return i
+		}
+		i = j + 1
+	}
+}
+
+// skipSemicolons is skipSpace skipping the semicolons as well.
+func skipSemicolons(src string, i int) int {
+	for {
+		i = skipSpace(src, i)
+		if i >= len(src) || src[i] != ';' {
+			return i
+		}
+		i++
+	}
+}
+
+func (m *meter) rewrite() string {
+	sort.SliceStable(m.insertions, func(i, j int) bool {
+		a, b := m.insertions[i], m.insertions[j]
+		if a.offset != b.offset {
+			return a.offset < b.offset
+		}
+		return a.closing && !b.closing
+	})
+	var b strings.Builder
+	last := 0
+	for _, ins := range m.insertions {
+		b.WriteString(m.src[last:ins.offset])
+		b.WriteString(ins.text)
+		last = ins.offset
+	}
+	b.WriteString(m.src[last:])
+	return b.String()
+}
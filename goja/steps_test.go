@@ -0,0 +1,171 @@
+package goja
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runProgram(program *goja.Program) func(runtime *goja.Runtime) (goja.Value, error) {
+	return func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunProgram(program)
+	}
+}
+
+func TestLimiterSteps(t *testing.T) {
+	program, err := CompileMetered("loop.js", loopScript)
+	require.NoError(t, err)
+
+	// 每次都在同一个位置停下，与机器的负载无关
+	for n := 0; n < 3; n++ {
+		vm := goja.New()
+		limiter := NewLimiter(vm, Limits{MaxSteps: 1000})
+		_, err := limiter.Run(context.Background(), runProgram(program))
+		assert.ErrorIs(t, err, ErrStepsExceeded)
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, int64(1001), limitErr.Used)
+		assert.Equal(t, int64(1000), vm.Get("i").ToInteger())
+	}
+}
+
+func TestLimiterStepsCatch(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxSteps: 100})
+
+	// try/catch 无法拦截超限，递归同样计数
+	program, err := CompileMetered("catch.js", `
+function spin(n) { return spin(n + 1); }
+for (;;) {
+	try {
+		spin(0);
+	} catch (e) {}
+}
+`)
+	require.NoError(t, err)
+	_, err = limiter.Run(context.Background(), runProgram(program))
+	assert.ErrorIs(t, err, ErrStepsExceeded)
+
+	// 计数在每次执行时重置，未超限的执行正常返回
+	program, err = CompileMetered("sum.js", `
+"use strict";
+function sum(list) {
+	"use strict";
+	var total = 0;
+	for (const v of list) total += v;
+	list.forEach(v => total += v);
+	do total++; while (false)
+	return total;
+}
+sum([1, 2, 3]);
+`)
+	require.NoError(t, err)
+	result, err := limiter.Run(context.Background(), runProgram(program))
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), result.Value.Export())
+	// 1 次 sum，3 次 for of，3 次箭头函数，1 次 do while
+	assert.Equal(t, int64(8), limiter.Steps())
+
+	// 最后一步超限同样能被发现
+	limiter = NewLimiter(vm, Limits{MaxSteps: 7})
+	_, err = limiter.Run(context.Background(), runProgram(program))
+	assert.ErrorIs(t, err, ErrStepsExceeded)
+}
+
+func TestLimiterStepsParentheses(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxSteps: 100})
+
+	// 以括号开头的循环体和函数体同样会计数，值为脚本的结果和步数
+	for src, want := range map[string][2]int64{
+		`var n = 0; for (var i = 0; i < 3; i++) (function (j) { n += j; })(i); n`: {3, 6},
+		`var i = 0; while (i < 3) (i++); i`:                                       {3, 3},
+		`var i = 0; while ((i < 3)) /* ) */ (i++); i`:                             {3, 3},
+		`var i = 0; for (;;) if (++i > 2) break; i`:                               {3, 3},
+		`var i = 0; for (; i < 3;) (i++); i`:                                      {3, 3},
+		`var i = 0; for (; i++ < 3;); i`:                                          {4, 3},
+		`var n = 0; for (var k in {a: 1, b: 2}) (n++); n`:                         {2, 2},
+		`var n = 0; for (const v of [1, 2]) (n += v); n`:                          {3, 2},
+		"var i = 0; do (i++)\nwhile (i < 3); i":                                   {3, 3},
+		`var i; function f() { (i = 1); return i; } f()`:                          {1, 1},
+		`var i; function f() { "use strict"; (i = 2); return i } f()`:             {2, 1},
+	} {
+		program, err := CompileMetered("paren.js", src)
+		if !assert.NoError(t, err, src) {
+			continue
+		}
+		result, err := limiter.Run(context.Background(), runProgram(program))
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, want[0], result.Value.Export(), src)
+			assert.Equal(t, want[1], limiter.Steps(), src)
+		}
+	}
+}
+
+func TestLimiterStepsBypass(t *testing.T) {
+	vm := goja.New()
+	limiter := NewLimiter(vm, Limits{MaxSteps: 100})
+
+	// 计数函数无法被删除或替换
+	program, err := CompileMetered("bypass.js", `
+delete globalThis["__goja" + "Step__"];
+globalThis["__goja" + "Step__"] = function () {};
+Object.defineProperty(globalThis, "__goja" + "Step__", {value: function () {}});
+`)
+	require.NoError(t, err)
+	_, err = limiter.Run(context.Background(), runProgram(program))
+	assert.ErrorContains(t, err, "TypeError: Cannot redefine property: __gojaStep__")
+
+	program, err = CompileMetered("bypass.js", `
+try { delete globalThis["__goja" + "Step__"]; } catch (e) {}
+try { globalThis["__goja" + "Step__"] = function () {}; } catch (e) {}
+for (;;) {}
+`)
+	require.NoError(t, err)
+	_, err = limiter.Run(context.Background(), runProgram(program))
+	assert.ErrorIs(t, err, ErrStepsExceeded)
+
+	// 声明同名的标识符或使用 with 遮蔽计数函数的脚本无法编译
+	for src, msg := range map[string]string{
+		"function f() {\n\tfunction __gojaStep__() {}\n\tfor (;;) {}\n}": "bypass.js:2:11: __gojaStep__ is reserved for the step counter",
+		`var __gojaStep__ = 1`:      "bypass.js:1:5: __gojaStep__ is reserved for the step counter",
+		`__gojaStep__ = null`:       "bypass.js:1:1: __gojaStep__ is reserved for the step counter",
+		`with ({}) { for (;;) {} }`: "bypass.js:1:1: with statements are not allowed in metered scripts",
+	} {
+		_, err := CompileMetered("bypass.js", src)
+		assert.EqualError(t, err, msg, src)
+	}
+
+	// 没有 MaxSteps 时不会定义计数函数
+	vm = goja.New()
+	result, err := NewLimiter(vm, Limits{}).Run(context.Background(), runString(`typeof __gojaStep__`))
+	require.NoError(t, err)
+	assert.Equal(t, "undefined", result.Value.String())
+}
+
+func TestLimiterStepsPoolIsolate(t *testing.T) {
+	sandbox := &Sandbox{}
+	pool := NewPool(PoolOptions{
+		Setup:   func(runtime *goja.Runtime) error { return sandbox.Apply(runtime) },
+		MaxIdle: 1,
+		Isolate: true,
+	})
+	program, err := CompileMetered("count.js", `var total = 0; for (var i = 0; i < 5; i++) total += i; total`)
+	require.NoError(t, err)
+
+	// 计数函数在还原时保留，runtime 不会因此被丢弃
+	for n := 0; n < 2; n++ {
+		rt, err := pool.Get()
+		require.NoError(t, err)
+		limiter := NewLimiter(rt, Limits{MaxSteps: 10})
+		result, err := limiter.Run(context.Background(), runProgram(program))
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), result.Value.Export())
+		assert.Equal(t, int64(5), limiter.Steps())
+		pool.Put(rt, err)
+	}
+	assert.Equal(t, PoolStats{Created: 1, Reused: 1, Idle: 1}, pool.Stats())
+}
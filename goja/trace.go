@@ -0,0 +1,119 @@
+package goja
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+)
+
+// ErrProfilerBusy is returned by Tracer.Profile while another profile is
+// being taken, the goja profiler being process wide.
+var ErrProfilerBusy = errors.New("goja: profiler already active")
+
+// ExecutionMetrics describes a finished execution.
+type ExecutionMetrics struct {
+	// Script is the name the execution was traced under.
+	Script string
+	// Duration and Interrupted are the ones of the Result.
+	Duration    time.Duration
+	Interrupted bool
+	// Exception is the name of the JS error that ended the execution, such
+	// as "TypeError", or "Error" when the thrown value is not an Error. It is
+	// empty when the execution did not end with an exception.
+	Exception string
+	// Err is the error returned by the execution.
+	Err error
+}
+
+// MetricsHook records the metrics of the executions of a Tracer. It is
+// called after each execution and must be safe for concurrent use.
+type MetricsHook interface {
+	RecordExecution(ctx context.Context, m ExecutionMetrics)
+}
+
+// Tracer runs scripts with WrapRun, reports their metrics to a MetricsHook
+// and profiles them on demand.
+type Tracer struct {
+	// Metrics receives the metrics of every execution, none are recorded
+	// when nil.
+	Metrics MetricsHook
+}
+
+// profiling serializes the profiles, StartProfile only fails once the
+// profiler is already taken.
+var profiling sync.Mutex
+
+// Run runs f with WrapRun and records its metrics under script.
+func (t *Tracer) Run(
+	ctx context.Context,
+	runtime *goja.Runtime,
+	script string,
+	f func(runtime *goja.Runtime) (goja.Value, error),
+) (Result, error) {
+	result, err := WrapRun(ctx, runtime, f)
+	t.record(ctx, script, result, err)
+	return result, err
+}
+
+// Profile is Run with the goja profiler enabled for the execution. The
+// profile measures the time spent executing JS instructions, sampled every
+// 10ms, and its samples are labeled with script. As the profiler is process
+// wide, other runtimes executing during the profile are sampled as well, and
+// Profile fails with ErrProfilerBusy while another profile is being taken.
+func (t *Tracer) Profile(
+	ctx context.Context,
+	runtime *goja.Runtime,
+	script string,
+	f func(runtime *goja.Runtime) (goja.Value, error),
+) (Result, *profile.Profile, error) {
+	if !profiling.TryLock() {
+		return Result{}, nil, ErrProfilerBusy
+	}
+	defer profiling.Unlock()
+
+	var buf bytes.Buffer
+	if err := goja.StartProfile(&buf); err != nil {
+		return Result{}, nil, errors.Wrap(ErrProfilerBusy, err.Error())
+	}
+	result, err := WrapRun(ctx, runtime, f)
+	goja.StopProfile()
+	t.record(ctx, script, result, err)
+
+	p, perr := profile.Parse(&buf)
+	if perr != nil {
+		return result, nil, errors.Wrap(perr, "failed to parse the profile")
+	}
+	p.Comments = append(p.Comments, "script: "+script)
+	for _, sample := range p.Sample {
+		if sample.Label == nil {
+			sample.Label = map[string][]string{}
+		}
+		sample.Label["script"] = []string{script}
+	}
+	return result, p, err
+}
+
+func (t *Tracer) record(ctx context.Context, script string, result Result, err error) {
+	if t.Metrics == nil {
+		return
+	}
+	m := ExecutionMetrics{
+		Script:      script,
+		Duration:    result.Duration,
+		Interrupted: result.Interrupted,
+		Err:         err,
+	}
+	var exception *ExceptionError
+	if errors.As(err, &exception) {
+		m.Exception = exception.Name
+		if m.Exception == "" {
+			m.Exception = "Error"
+		}
+	}
+	t.Metrics.RecordExecution(ctx, m)
+}
@@ -0,0 +1,125 @@
+package goja
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordMetrics struct {
+	mu      sync.Mutex
+	metrics []ExecutionMetrics
+}
+
+func (r *recordMetrics) RecordExecution(_ context.Context, m ExecutionMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+func TestTracerMetrics(t *testing.T) {
+	metrics := &recordMetrics{}
+	tracer := &Tracer{Metrics: metrics}
+	vm := goja.New()
+
+	_, err := tracer.Run(context.Background(), vm, "ok.js", runString(`1 + 1`))
+	require.NoError(t, err)
+
+	_, err = tracer.Run(context.Background(), vm, "throw.js", runString(`null.x`))
+	require.Error(t, err)
+
+	_, err = tracer.Run(context.Background(), vm, "string.js", runString(`throw "oops"`))
+	require.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = tracer.Run(ctx, vm, "loop.js", runString(loopScript))
+	require.Error(t, err)
+
+	require.Len(t, metrics.metrics, 4)
+	assert.Equal(t, "ok.js", metrics.metrics[0].Script)
+	assert.Equal(t, "", metrics.metrics[0].Exception)
+	assert.NoError(t, metrics.metrics[0].Err)
+
+	assert.Equal(t, "TypeError", metrics.metrics[1].Exception)
+	assert.Error(t, metrics.metrics[1].Err)
+	assert.Equal(t, "Error", metrics.metrics[2].Exception)
+
+	assert.True(t, metrics.metrics[3].Interrupted)
+	assert.GreaterOrEqual(t, metrics.metrics[3].Duration, 10*time.Millisecond)
+	assert.Equal(t, "", metrics.metrics[3].Exception)
+	assert.ErrorIs(t, metrics.metrics[3].Err, context.DeadlineExceeded)
+
+	// 没有 hook 时正常执行
+	result, err := (&Tracer{}).Run(context.Background(), vm, "ok.js", runString(`1 + 1`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Value.Export())
+}
+
+func TestTracerProfile(t *testing.T) {
+	metrics := &recordMetrics{}
+	tracer := &Tracer{Metrics: metrics}
+	vm := goja.New()
+
+	result, p, err := tracer.Profile(context.Background(), vm, "hot.js", func(runtime *goja.Runtime) (goja.Value, error) {
+		return runtime.RunScript("hot.js", `
+function hot() {
+	var end = Date.now() + 100, n = 0;
+	while (Date.now() < end) {
+		n++;
+	}
+	return n;
+}
+hot() > 0;
+`)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Value.Export())
+	require.Len(t, metrics.metrics, 1)
+
+	require.NotNil(t, p)
+	require.NotEmpty(t, p.Sample)
+	assert.Contains(t, p.Comments, "script: hot.js")
+	for _, sample := range p.Sample {
+		assert.Equal(t, []string{"hot.js"}, sample.Label["script"])
+	}
+	var funcs []string
+	for _, fn := range p.Function {
+		if fn.Filename == "hot.js" {
+			funcs = append(funcs, fn.Name)
+		}
+	}
+	assert.Contains(t, funcs, "hot")
+}
+
+func TestTracerProfileBusy(t *testing.T) {
+	tracer := &Tracer{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, _, err := tracer.Profile(context.Background(), goja.New(), "slow.js", func(*goja.Runtime) (goja.Value, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		done <- err
+	}()
+	<-started
+
+	// 同一时间只能有一个 profile
+	_, _, err := tracer.Profile(context.Background(), goja.New(), "other.js", runString(`1`))
+	assert.ErrorIs(t, err, ErrProfilerBusy)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	_, p, err := tracer.Profile(context.Background(), goja.New(), "other.js", runString(`1`))
+	require.NoError(t, err)
+	assert.NotNil(t, p)
+}
@@ -0,0 +1,96 @@
+package json
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+// Analyzer reports struct types declared in a package whose JSON encoding
+// silently drops fields or leaves embedded interfaces unpromoted, the same
+// conflicts CheckType finds at runtime.
+var Analyzer = &analysis.Analyzer{
+	Name: "jsonconflict",
+	Doc:  "report struct fields that encoding/json silently drops because of embedded-struct conflicts",
+	Run:  runAnalyzer,
+}
+
+func runAnalyzer(pass *analysis.Pass) (any, error) {
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			obj := pass.TypesInfo.Defs[spec.Name]
+			if obj == nil {
+				return true
+			}
+			// the fields of a generic type are resolved with its type
+			// parameters, which cannot be embedded and never add a field
+			if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+				return true
+			}
+			res := structfield.Resolve(goType{obj.Type()})
+			for _, c := range conflictsOf(obj.Name(), res) {
+				pass.Reportf(spec.Pos(), "%s", c)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// goType adapts types.Type to structfield.Type.
+type goType struct {
+	types.Type
+}
+
+func (t goType) Kind() structfield.Kind {
+	if _, ok := types.Unalias(t.Type).(*types.TypeParam); ok {
+		return structfield.Other
+	}
+	switch t.Type.Underlying().(type) {
+	case *types.Struct:
+		return structfield.Struct
+	case *types.Pointer:
+		return structfield.Pointer
+	case *types.Interface:
+		return structfield.Interface
+	default:
+		return structfield.Other
+	}
+}
+
+func (t goType) Elem() structfield.Type {
+	return goType{t.Type.Underlying().(*types.Pointer).Elem()}
+}
+
+func (t goType) Named() bool {
+	_, ok := types.Unalias(t.Type).(*types.Named)
+	return ok
+}
+
+func (t goType) NumField() int {
+	return t.Type.Underlying().(*types.Struct).NumFields()
+}
+
+func (t goType) Field(i int) structfield.Field {
+	s := t.Type.Underlying().(*types.Struct)
+	v := s.Field(i)
+	return structfield.Field{
+		Name:      v.Name(),
+		Tag:       reflect.StructTag(s.Tag(i)),
+		Anonymous: v.Embedded(),
+		Exported:  v.Exported(),
+		Type:      goType{v.Type()},
+	}
+}
+
+func (t goType) Key() any {
+	return types.Unalias(t.Type)
+}
@@ -0,0 +1,259 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Canonicalize rewrites the JSON document data into its canonical form as
+// defined by the JSON Canonicalization Scheme (RFC 8785): no whitespace,
+// object members sorted by the UTF-16 code units of their names, numbers
+// serialized like ECMAScript and strings with minimal escaping.
+//
+// Duplicate member names, numbers that are not finite IEEE 754 doubles,
+// invalid UTF-8 and lone surrogates are rejected as required by I-JSON
+// (RFC 7493).
+func Canonicalize(data []byte) ([]byte, error) {
+	if err := validateStrings(data); err != nil {
+		return nil, errors.Wrap(err, "json: canonicalize")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := canonicalValue(dec, &buf); err != nil {
+		return nil, errors.Wrap(err, "json: canonicalize")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("json: canonicalize: unexpected data after top-level value")
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalCanonical is like json.Marshal but returns the canonical form of
+// the encoding, suitable for hashing and signing.
+func MarshalCanonical(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(data)
+}
+
+func canonicalValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			return canonicalObject(dec, buf)
+		case '[':
+			return canonicalArray(dec, buf)
+		default:
+			return errors.Errorf("unexpected delimiter %q", tok)
+		}
+	case string:
+		writeCanonicalString(buf, tok)
+	case json.Number:
+		s, err := canonicalNumber(tok)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case bool:
+		buf.WriteString(strconv.FormatBool(tok))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func canonicalObject(dec *json.Decoder, buf *bytes.Buffer) error {
+	type member struct {
+		name  string
+		key   []uint16
+		value []byte
+	}
+	var members []member
+	seen := map[string]bool{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		if seen[name] {
+			return errors.Errorf("duplicate member name %q", name)
+		}
+		seen[name] = true
+		var value bytes.Buffer
+		if err := canonicalValue(dec, &value); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, key: utf16.Encode([]rune(name)), value: value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i].key, members[j].key
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeCanonicalString(buf, m.name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func canonicalArray(dec *json.Decoder, buf *bytes.Buffer) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := canonicalValue(dec, buf); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// canonicalNumber formats n as ECMAScript Number.prototype.toString does
+// for the nearest IEEE 754 double.
+func canonicalNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", errors.Errorf("number %s is not representable as a finite double", n)
+	}
+	return formatES6Number(f), nil
+}
+
+func formatES6Number(f float64) string {
+	if f == 0 {
+		return "0" // also -0
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		// Go always prints at least two exponent digits: 1e-07 => 1e-7.
+		n := len(s)
+		if n >= 4 && s[n-4] == 'e' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// validateStrings rejects invalid UTF-8 and escaped lone surrogates in data,
+// which the decoder would silently replace with U+FFFD.
+func validateStrings(data []byte) error {
+	if !utf8.Valid(data) {
+		return errors.New("invalid UTF-8")
+	}
+	inString := false
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			inString = !inString
+		case c == '\\' && inString:
+			i++
+			if i >= len(data) || data[i] != 'u' {
+				continue
+			}
+			r, ok := escapedRune(data[i+1:])
+			if !ok {
+				continue
+			}
+			i += 4
+			switch {
+			case utf16.IsSurrogate(r) && r < 0xdc00:
+				if len(data) > i+2 && data[i+1] == '\\' && data[i+2] == 'u' {
+					if low, ok := escapedRune(data[i+3:]); ok && low >= 0xdc00 && low <= 0xdfff {
+						i += 6
+						continue
+					}
+				}
+				return errors.Errorf("lone surrogate %U", r)
+			case utf16.IsSurrogate(r):
+				return errors.Errorf("lone surrogate %U", r)
+			}
+		}
+	}
+	return nil
+}
+
+// escapedRune decodes the 4 hex digits of a \u escape.
+func escapedRune(data []byte) (rune, bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(data[:4]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
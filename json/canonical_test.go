@@ -0,0 +1,124 @@
+package json
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	{
+		// RFC 8785 3.2.2
+		input := `{
+			"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false]
+		}`
+		result, err := Canonicalize([]byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`, string(result))
+	}
+	{
+		// RFC 8785 3.2.3 ，按 UTF-16 code unit 排序，而不是按 UTF-8 字节或 rune 排序
+		input := `{
+			"\u20ac": "Euro Sign",
+			"\r": "Carriage Return",
+			"\ufb33": "Hebrew Letter Dalet With Dagesh",
+			"1": "One",
+			"\ud83d\ude00": "Emoji: Grinning Face",
+			"\u0080": "Control",
+			"\u00f6": "Latin Small Letter O With Diaeresis"
+		}`
+		result, err := Canonicalize([]byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, "{"+
+			`"\r":"Carriage Return",`+
+			`"1":"One",`+
+			"\"\u0080\":\"Control\","+
+			`"ö":"Latin Small Letter O With Diaeresis",`+
+			`"€":"Euro Sign",`+
+			`"😀":"Emoji: Grinning Face",`+
+			"\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\""+
+			"}", string(result))
+	}
+	{
+		_, err := Canonicalize([]byte(`{"a":1,"a":2}`))
+		assert.ErrorContains(t, err, `duplicate member name "a"`)
+
+		_, err = Canonicalize([]byte(`1e400`))
+		assert.ErrorContains(t, err, "not representable")
+
+		_, err = Canonicalize([]byte(`{} {}`))
+		assert.ErrorContains(t, err, "unexpected data after top-level value")
+	}
+	{
+		// 不合法的 UTF-8 和孤立的代理项不会被替换为 U+FFFD，而是报错
+		_, err := Canonicalize([]byte("\"\xff\""))
+		assert.ErrorContains(t, err, "invalid UTF-8")
+
+		_, err = Canonicalize([]byte(`{"\ud83d": 1}`))
+		assert.ErrorContains(t, err, "lone surrogate U+D83D")
+
+		_, err = Canonicalize([]byte(`["\ude00\ud83d"]`))
+		assert.ErrorContains(t, err, "lone surrogate U+DE00")
+
+		_, err = Canonicalize([]byte(`"\ud83d\ud83d\ude00"`))
+		assert.ErrorContains(t, err, "lone surrogate U+D83D")
+
+		// 被转义的反斜杠后面不是转义序列
+		result, err := Canonicalize([]byte(`"\\ud83d"`))
+		require.NoError(t, err)
+		assert.Equal(t, `"\\ud83d"`, string(result))
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	// RFC 8785 Appendix B
+	cases := map[uint64]string{
+		0x0000000000000000: "0",
+		0x8000000000000000: "0",
+		0x0000000000000001: "5e-324",
+		0x8000000000000001: "-5e-324",
+		0x7fefffffffffffff: "1.7976931348623157e+308",
+		0xffefffffffffffff: "-1.7976931348623157e+308",
+		0x4340000000000000: "9007199254740992",
+		0xc340000000000000: "-9007199254740992",
+		0x4430000000000000: "295147905179352830000",
+		0x44b52d02c7e14af5: "9.999999999999997e+22",
+		0x44b52d02c7e14af6: "1e+23",
+		0x44b52d02c7e14af7: "1.0000000000000001e+23",
+		0x444b1ae4d6e2ef4e: "999999999999999700000",
+		0x444b1ae4d6e2ef4f: "999999999999999900000",
+		0x444b1ae4d6e2ef50: "1e+21",
+		0x3eb0c6f7a0b5ed8c: "9.999999999999997e-7",
+		0x3eb0c6f7a0b5ed8d: "0.000001",
+		0x41b3de4355555553: "333333333.3333332",
+		0x41b3de4355555554: "333333333.33333325",
+		0x41b3de4355555555: "333333333.3333333",
+		0x41b3de4355555556: "333333333.3333334",
+		0x41b3de4355555557: "333333333.33333343",
+		0xbecbf647612f3696: "-0.0000033333333333333333",
+		0x43143ff3c1cb0959: "1424953923781206.2",
+	}
+	for bits, expected := range cases {
+		assert.Equal(t, expected, formatES6Number(math.Float64frombits(bits)), "%016x", bits)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	// encoding/json 对 map 会排序，但 struct 字段按声明顺序，并且会转义 HTML 字符
+	type Doc struct {
+		Z    string         `json:"z"`
+		A    map[string]any `json:"a"`
+		HTML string         `json:"html"`
+	}
+	result, err := MarshalCanonical(Doc{
+		Z:    "z",
+		A:    map[string]any{"b": 1.0, "a": map[string]any{"y": 100.0, "x": 1e21}},
+		HTML: "<a&b>",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"a":{"x":1e+21,"y":100},"b":1},"html":"<a&b>","z":"z"}`, string(result))
+}
@@ -0,0 +1,167 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+// ConflictKind classifies a Conflict.
+type ConflictKind int
+
+const (
+	// ConflictAmbiguous means several fields at the same depth share a JSON
+	// name and none dominates, so encoding/json drops all of them.
+	ConflictAmbiguous ConflictKind = iota + 1
+	// ConflictShadowed means a tagged field wins over untagged fields with
+	// the same JSON name at the same depth, which are dropped.
+	ConflictShadowed
+	// ConflictEmbeddedInterface means an embedded interface whose fields are
+	// not promoted, it is encoded as a nested object under its type name.
+	ConflictEmbeddedInterface
+)
+
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictAmbiguous:
+		return "ambiguous"
+	case ConflictShadowed:
+		return "shadowed"
+	case ConflictEmbeddedInterface:
+		return "embedded interface"
+	default:
+		return fmt.Sprintf("ConflictKind(%d)", int(k))
+	}
+}
+
+// Conflict describes fields of a struct type that encoding/json silently
+// drops or does not promote.
+type Conflict struct {
+	Kind ConflictKind
+	// Type is the struct type that contains the conflict.
+	Type string
+	// Name is the JSON name involved.
+	Name string
+	// Fields are the Go field paths involved, e.g. "A.ID".
+	// For ConflictShadowed the first one is the winner.
+	Fields []string
+}
+
+func (c Conflict) String() string {
+	switch c.Kind {
+	case ConflictAmbiguous:
+		return fmt.Sprintf("%s: json field %q is dropped, ambiguous between %s", c.Type, c.Name, strings.Join(c.Fields, ", "))
+	case ConflictShadowed:
+		return fmt.Sprintf("%s: json field %q of %s is dropped, shadowed by tagged %s", c.Type, c.Name, strings.Join(c.Fields[1:], ", "), c.Fields[0])
+	case ConflictEmbeddedInterface:
+		return fmt.Sprintf("%s: embedded interface %s is not promoted, encoded as nested object %q", c.Type, c.Fields[0], c.Name)
+	default:
+		return fmt.Sprintf("%s: %s conflict on %q", c.Type, c.Kind, c.Name)
+	}
+}
+
+// CheckType reports the conflicts of t and of every struct type reachable
+// from it through fields, pointers, slices, arrays and maps.
+func CheckType(t reflect.Type) []Conflict {
+	var conflicts []Conflict
+	visited := map[reflect.Type]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		if t == nil || visited[t] {
+			return
+		}
+		visited[t] = true
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			walk(t.Elem())
+		case reflect.Map:
+			walk(t.Key())
+			walk(t.Elem())
+		case reflect.Struct:
+			res := structfield.Resolve(reflectType{t})
+			conflicts = append(conflicts, conflictsOf(t.String(), res)...)
+			for _, f := range res.Fields {
+				walk(f.Type.(reflectType).Type)
+			}
+		}
+	}
+	walk(t)
+	return conflicts
+}
+
+func conflictsOf(typeName string, res structfield.Result) []Conflict {
+	var conflicts []Conflict
+	for _, d := range res.Dropped {
+		c := Conflict{Type: typeName, Name: d.Name}
+		if d.Winner == nil {
+			c.Kind = ConflictAmbiguous
+		} else {
+			c.Kind = ConflictShadowed
+			c.Fields = append(c.Fields, fieldPath(*d.Winner))
+		}
+		for _, f := range d.Candidates {
+			if d.Winner != nil && slices.Equal(f.Index, d.Winner.Index) {
+				continue
+			}
+			c.Fields = append(c.Fields, fieldPath(f))
+		}
+		conflicts = append(conflicts, c)
+	}
+	for _, f := range res.Interfaces {
+		conflicts = append(conflicts, Conflict{
+			Kind:   ConflictEmbeddedInterface,
+			Type:   typeName,
+			Name:   f.Name,
+			Fields: []string{fieldPath(f)},
+		})
+	}
+	return conflicts
+}
+
+func fieldPath(f structfield.Resolved) string {
+	return strings.Join(f.Path, ".")
+}
+
+// reflectType adapts reflect.Type to structfield.Type.
+type reflectType struct {
+	reflect.Type
+}
+
+func (t reflectType) Kind() structfield.Kind {
+	switch t.Type.Kind() {
+	case reflect.Struct:
+		return structfield.Struct
+	case reflect.Pointer:
+		return structfield.Pointer
+	case reflect.Interface:
+		return structfield.Interface
+	default:
+		return structfield.Other
+	}
+}
+
+func (t reflectType) Elem() structfield.Type {
+	return reflectType{t.Type.Elem()}
+}
+
+func (t reflectType) Named() bool {
+	return t.Type.Name() != ""
+}
+
+func (t reflectType) Field(i int) structfield.Field {
+	sf := t.Type.Field(i)
+	return structfield.Field{
+		Name:      sf.Name,
+		Tag:       sf.Tag,
+		Anonymous: sf.Anonymous,
+		Exported:  sf.IsExported(),
+		Type:      reflectType{sf.Type},
+	}
+}
+
+func (t reflectType) Key() any {
+	return t.Type
+}
@@ -0,0 +1,68 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckType(t *testing.T) {
+	{
+		// C 只 embed 了 A ，没有冲突
+		assert.Empty(t, CheckType(reflect.TypeOf(C{})))
+	}
+	{
+		// D 里 A.ID 和 B.ID 都叫 id ，encoding/json 会把俩都丢掉
+		conflicts := CheckType(reflect.TypeOf(D{}))
+		assert.Equal(t, []Conflict{
+			{Kind: ConflictAmbiguous, Type: "json.D", Name: "id", Fields: []string{"A.ID", "B.ID"}},
+		}, conflicts)
+		assert.Equal(t, `json.D: json field "id" is dropped, ambiguous between A.ID, B.ID`, conflicts[0].String())
+	}
+	{
+		// embed 的 interface 不会被展开
+		assert.Equal(t, []Conflict{
+			{Kind: ConflictEmbeddedInterface, Type: "json.E", Name: "Identifiable", Fields: []string{"Identifiable"}},
+		}, CheckType(reflect.TypeOf(E{})))
+		assert.Equal(t, []Conflict{
+			{Kind: ConflictEmbeddedInterface, Type: "json.H", Name: "Identifiable", Fields: []string{"Identifiable"}},
+		}, CheckType(reflect.TypeOf(&H{})))
+	}
+	{
+		assert.Empty(t, CheckType(reflect.TypeOf(G{})))
+	}
+	{
+		// 同一层里带 tag 的字段会优先，未带 tag 的同名字段被静默丢弃
+		type X struct {
+			Name string
+		}
+		type Y struct {
+			Title string `json:"Name"`
+		}
+		type Z struct {
+			X
+			Y
+		}
+		assert.Equal(t, []Conflict{
+			{Kind: ConflictShadowed, Type: "json.Z", Name: "Name", Fields: []string{"Y.Title", "X.Name"}},
+		}, CheckType(reflect.TypeOf(Z{})))
+	}
+	{
+		// 更浅层的字段覆盖深层字段是正常的提升规则，不算冲突
+		type Outer struct {
+			A
+			ID string `json:"id"`
+		}
+		assert.Empty(t, CheckType(reflect.TypeOf(Outer{})))
+	}
+	{
+		// 会递归检查字段里引用到的类型
+		type Wrapper struct {
+			Items map[string][]*D `json:"items"`
+		}
+		conflicts := CheckType(reflect.TypeOf(Wrapper{}))
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, "json.D", conflicts[0].Type)
+	}
+}
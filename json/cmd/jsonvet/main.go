@@ -0,0 +1,17 @@
+// Command jsonvet reports struct fields that encoding/json silently drops.
+//
+// Usage:
+//
+//	go vet -vettool=$(which jsonvet) ./...
+//	jsonvet ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/molon/tests/json"
+)
+
+func main() {
+	singlechecker.Main(json.Analyzer)
+}
@@ -0,0 +1,796 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+// Codec marshals values of T with encoders precomputed once per type and
+// pooled buffers. The output is byte for byte the output of json.Marshal:
+// field resolution (including the embedded-struct conflict rules), omitempty,
+// the string option, HTML escaping and Marshaler handling are identical.
+//
+// Decoding is delegated to encoding/json, which already caches its decoders
+// per type. A Codec is safe for concurrent use.
+type Codec[T any] struct {
+	enc encoderFunc
+	// addressable is false when T reaches a type with pointer receiver
+	// marshalers, which encoding/json only honors on addressable values, so
+	// the value must be passed like json.Marshal(v) does.
+	addressable bool
+	kind        reflect.Kind
+}
+
+// NewCodec returns a Codec for T.
+func NewCodec[T any]() *Codec[T] {
+	t := reflect.TypeFor[T]()
+	return &Codec[T]{
+		enc:         typeEncoder(t),
+		addressable: !reachesAddrMarshaler(t, map[reflect.Type]bool{}),
+		kind:        t.Kind(),
+	}
+}
+
+// Marshal returns the JSON encoding of v.
+func (c *Codec[T]) Marshal(v T) ([]byte, error) {
+	e := encodeStatePool.Get().(*encodeState)
+	defer e.release()
+	if err := c.encode(e, v); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+// Append appends the JSON encoding of v to dst, which saves the copy
+// Marshal makes out of its pooled buffer.
+func (c *Codec[T]) Append(dst []byte, v T) ([]byte, error) {
+	e := encodeStatePool.Get().(*encodeState)
+	defer e.release()
+	if err := c.encode(e, v); err != nil {
+		return dst, err
+	}
+	return append(dst, e.buf...), nil
+}
+
+// Unmarshal decodes data into a new T like Unmarshal[T].
+func (c *Codec[T]) Unmarshal(data []byte) (T, error) {
+	return Unmarshal[T](data)
+}
+
+func (c *Codec[T]) encode(e *encodeState, v T) error {
+	var rv reflect.Value
+	if c.addressable && c.kind != reflect.Pointer {
+		rv = reflect.ValueOf(&v).Elem()
+	} else {
+		rv = reflect.ValueOf(any(v))
+		if !rv.IsValid() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+	}
+	return c.enc(e, rv, encOpts{})
+}
+
+func reachesAddrMarshaler(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	if t.Kind() != reflect.Pointer {
+		pt := reflect.PointerTo(t)
+		if (pt.Implements(marshalerType) && !t.Implements(marshalerType)) ||
+			(pt.Implements(textMarshalerType) && !t.Implements(textMarshalerType)) {
+			return true
+		}
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return reachesAddrMarshaler(t.Elem(), visited)
+	case reflect.Map:
+		return reachesAddrMarshaler(t.Key(), visited) || reachesAddrMarshaler(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if reachesAddrMarshaler(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const startDetectingCyclesAfter = 1000
+
+type encodeState struct {
+	buf      []byte
+	ptrLevel uint
+	ptrSeen  map[any]struct{}
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() any {
+		return &encodeState{ptrSeen: map[any]struct{}{}}
+	},
+}
+
+func (e *encodeState) release() {
+	if cap(e.buf) > 64<<10 {
+		return // let oversized buffers go
+	}
+	e.buf = e.buf[:0]
+	e.ptrLevel = 0
+	clear(e.ptrSeen)
+	encodeStatePool.Put(e)
+}
+
+type encOpts struct {
+	// quoted causes primitive fields to be encoded inside JSON strings.
+	quoted bool
+}
+
+type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts) error
+
+var encoderCache sync.Map // reflect.Type -> encoderFunc
+
+func typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := encoderCache.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+
+	// Store an indirect func that waits for the real one so that recursive
+	// types can refer to themselves while being built.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value, opts encOpts) error {
+		wg.Wait()
+		return f(e, v, opts)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = newTypeEncoder(t, true)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	if t == timeType {
+		return timeEncoder
+	}
+	if t.Kind() != reflect.Pointer && allowAddr && reflect.PointerTo(t).Implements(marshalerType) {
+		return condAddrEncoder(addrMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+	if t.Kind() != reflect.Pointer && allowAddr && reflect.PointerTo(t).Implements(textMarshalerType) {
+		return condAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(textMarshalerType) {
+		return textMarshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintEncoder
+	case reflect.Float32:
+		return floatEncoder(32)
+	case reflect.Float64:
+		return floatEncoder(64)
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Slice:
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newArrayEncoder(t)
+	case reflect.Pointer:
+		return newPtrEncoder(t)
+	default:
+		return unsupportedTypeEncoder
+	}
+}
+
+func unsupportedTypeEncoder(_ *encodeState, v reflect.Value, _ encOpts) error {
+	return &json.UnsupportedTypeError{Type: v.Type()}
+}
+
+func marshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) error {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	m, ok := v.Interface().(json.Marshaler)
+	if !ok {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeMarshaled(v.Type(), m)
+}
+
+func addrMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) error {
+	va := v.Addr()
+	if va.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeMarshaled(v.Type(), va.Interface().(json.Marshaler))
+}
+
+func (e *encodeState) writeMarshaled(t reflect.Type, m json.Marshaler) error {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	if !json.Valid(b) {
+		// let json.Compact describe the syntax error
+		var scratch bytes.Buffer
+		err := json.Compact(&scratch, b)
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	e.buf = appendCompact(e.buf, b)
+	return nil
+}
+
+// appendCompact appends the valid JSON src without insignificant whitespace
+// and with the HTML escaping of json.HTMLEscape, like json.Compact followed
+// by json.HTMLEscape in a single pass.
+func appendCompact(dst, src []byte) []byte {
+	inString := false
+	start := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString {
+			switch {
+			case c == '\\':
+				i++ // an escaped character can't end the string
+			case c == '"':
+				inString = false
+			case c == '<' || c == '>' || c == '&':
+				dst = append(dst, src[start:i]...)
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+				start = i + 1
+			case c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8:
+				// U+2028 is LINE SEPARATOR, U+2029 is PARAGRAPH SEPARATOR.
+				dst = append(dst, src[start:i]...)
+				dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[src[i+2]&0xF])
+				start = i + 3
+				i += 2
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case ' ', '\t', '\n', '\r':
+			dst = append(dst, src[start:i]...)
+			start = i + 1
+		}
+	}
+	return append(dst, src[start:]...)
+}
+
+// timeEncoder formats time.Time in place of its MarshalJSON, which allocates,
+// and falls back to it for the times it rejects.
+func timeEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	var t time.Time
+	if v.CanAddr() {
+		t = *v.Addr().Interface().(*time.Time)
+	} else {
+		t = v.Interface().(time.Time)
+	}
+	_, offset := t.Zone()
+	if y := t.Year(); y < 0 || y > 9999 || offset <= -24*60*60 || offset >= 24*60*60 || offset%60 != 0 {
+		return e.writeMarshaled(timeType, t)
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = t.AppendFormat(e.buf, time.RFC3339Nano)
+	e.buf = append(e.buf, '"')
+	return nil
+}
+
+func textMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) error {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeText(v.Type(), m)
+}
+
+func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) error {
+	va := v.Addr()
+	if va.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeText(v.Type(), va.Interface().(encoding.TextMarshaler))
+}
+
+func (e *encodeState) writeText(t reflect.Type, m encoding.TextMarshaler) error {
+	b, err := m.MarshalText()
+	if err != nil {
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	e.buf = appendString(e.buf, string(b), true)
+	return nil
+}
+
+func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	b := e.buf
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	b = strconv.AppendBool(b, v.Bool())
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	e.buf = b
+	return nil
+}
+
+func intEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	b := e.buf
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	b = strconv.AppendInt(b, v.Int(), 10)
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	e.buf = b
+	return nil
+}
+
+func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	b := e.buf
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	b = strconv.AppendUint(b, v.Uint(), 10)
+	if opts.quoted {
+		b = append(b, '"')
+	}
+	e.buf = b
+	return nil
+}
+
+func floatEncoder(bits int) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) error {
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+		}
+
+		// Convert as if by ES6 number to string conversion, like
+		// encoding/json does.
+		b := e.buf
+		if opts.quoted {
+			b = append(b, '"')
+		}
+		abs := math.Abs(f)
+		format := byte('f')
+		if abs != 0 {
+			if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+				format = 'e'
+			}
+		}
+		b = strconv.AppendFloat(b, f, format, -1, bits)
+		if format == 'e' {
+			// clean up e-09 to e-9
+			n := len(b)
+			if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+				b[n-2] = b[n-1]
+				b = b[:n-1]
+			}
+		}
+		if opts.quoted {
+			b = append(b, '"')
+		}
+		e.buf = b
+		return nil
+	}
+}
+
+var numberType = reflect.TypeFor[json.Number]()
+
+func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	if v.Type() == numberType {
+		numStr := v.String()
+		// In Go1.5 the empty string encodes to "0", while this is not a
+		// valid number literal we keep compatibility so check validity after
+		// this.
+		if numStr == "" {
+			numStr = "0"
+		}
+		if !isValidNumber(numStr) {
+			return &json.UnsupportedValueError{Value: v, Str: strconv.Quote(numStr)}
+		}
+		if opts.quoted {
+			e.buf = append(e.buf, '"')
+		}
+		e.buf = append(e.buf, numStr...)
+		if opts.quoted {
+			e.buf = append(e.buf, '"')
+		}
+		return nil
+	}
+	if opts.quoted {
+		b := appendString(nil, v.String(), true)
+		e.buf = appendString(e.buf, string(b), false) // already escaped
+		return nil
+	}
+	e.buf = appendString(e.buf, v.String(), true)
+	return nil
+}
+
+// isValidNumber reports whether s is a valid JSON number literal.
+func isValidNumber(s string) bool {
+	// This function implements the JSON numbers grammar.
+	// See https://tools.ietf.org/html/rfc7159#section-6
+	// and https://www.json.org/img/number.png
+
+	if s == "" {
+		return false
+	}
+
+	// Optional -
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	// Digits
+	switch {
+	default:
+		return false
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// . followed by 1 or more digits.
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// e or E followed by an optional - or + and
+	// 1 or more digits.
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// Make sure we are at the end.
+	return s == ""
+}
+
+func interfaceEncoder(e *encodeState, v reflect.Value, opts encOpts) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	elem := v.Elem()
+	return typeEncoder(elem.Type())(e, elem, opts)
+}
+
+type codecField struct {
+	name      []byte // `"name":` escaped like encoding/json
+	index     []int
+	omitEmpty bool
+	quoted    bool
+	encoder   encoderFunc
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	var fields []codecField
+	for _, f := range structfield.Resolve(reflectType{t}).Fields {
+		ft := f.Type.(reflectType).Type
+		name := appendString(nil, f.Name, true)
+		fields = append(fields, codecField{
+			name:      append(name, ':'),
+			index:     f.Index,
+			omitEmpty: f.OmitEmpty,
+			quoted:    f.Quoted && isQuotable(ft),
+			encoder:   typeEncoder(ft),
+		})
+	}
+	return func(e *encodeState, v reflect.Value, _ encOpts) error {
+		next := byte('{')
+	FieldLoop:
+		for i := range fields {
+			f := &fields[i]
+			fv := v
+			for _, i := range f.index {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						continue FieldLoop
+					}
+					fv = fv.Elem()
+				}
+				fv = fv.Field(i)
+			}
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			e.buf = append(e.buf, next)
+			next = ','
+			e.buf = append(e.buf, f.name...)
+			if err := f.encoder(e, fv, encOpts{quoted: f.quoted}); err != nil {
+				return err
+			}
+		}
+		if next == '{' {
+			e.buf = append(e.buf, "{}"...)
+		} else {
+			e.buf = append(e.buf, '}')
+		}
+		return nil
+	}
+}
+
+// unnamedElem dereferences an unnamed pointer type, the string option of
+// encoding/json applies through it.
+func unnamedElem(t reflect.Type) reflect.Type {
+	if t.Name() == "" && t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !t.Key().Implements(textMarshalerType) {
+			return unsupportedTypeEncoder
+		}
+	}
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *encodeState, v reflect.Value, _ encOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+			// We're a large number of nested ptrEncoder calls deep;
+			// start checking if we've run into a pointer cycle.
+			ptr := v.UnsafePointer()
+			if _, ok := e.ptrSeen[ptr]; ok {
+				return &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+			}
+			e.ptrSeen[ptr] = struct{}{}
+			defer delete(e.ptrSeen, ptr)
+		}
+		type kv struct {
+			key   string
+			value reflect.Value
+		}
+		entries := make([]kv, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			ks, err := mapKeyString(iter.Key())
+			if err != nil {
+				return &json.MarshalerError{Type: t.Key(), Err: err}
+			}
+			entries = append(entries, kv{ks, iter.Value()})
+		}
+		slices.SortFunc(entries, func(a, b kv) int { return strings.Compare(a.key, b.key) })
+
+		e.buf = append(e.buf, '{')
+		for i, entry := range entries {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = appendString(e.buf, entry.key, true)
+			e.buf = append(e.buf, ':')
+			if err := elemEnc(e, entry.value, encOpts{}); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+		e.ptrLevel--
+		return nil
+	}
+}
+
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	// Byte slices get special treatment; arrays don't.
+	if t.Elem().Kind() == reflect.Uint8 {
+		p := reflect.PointerTo(t.Elem())
+		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
+			return bytesEncoder
+		}
+	}
+	arrayEnc := newArrayEncoder(t)
+	return func(e *encodeState, v reflect.Value, opts encOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+			// We're a large number of nested ptrEncoder calls deep;
+			// start checking if we've run into a pointer cycle.
+			// Here we use a struct to memorize the pointer to the first element of the slice
+			// and its length.
+			ptr := struct {
+				ptr any // always an unsafe.Pointer, but avoids a dependency on package unsafe
+				len int
+			}{v.UnsafePointer(), v.Len()}
+			if _, ok := e.ptrSeen[ptr]; ok {
+				return &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+			}
+			e.ptrSeen[ptr] = struct{}{}
+			defer delete(e.ptrSeen, ptr)
+		}
+		err := arrayEnc(e, v, opts)
+		e.ptrLevel--
+		return err
+	}
+}
+
+func bytesEncoder(e *encodeState, v reflect.Value, _ encOpts) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = base64.StdEncoding.AppendEncode(e.buf, v.Bytes())
+	e.buf = append(e.buf, '"')
+	return nil
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *encodeState, v reflect.Value, _ encOpts) error {
+		e.buf = append(e.buf, '[')
+		n := v.Len()
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if err := elemEnc(e, v.Index(i), encOpts{}); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+		return nil
+	}
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *encodeState, v reflect.Value, opts encOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+			// We're a large number of nested ptrEncoder calls deep;
+			// start checking if we've run into a pointer cycle.
+			ptr := v.Interface()
+			if _, ok := e.ptrSeen[ptr]; ok {
+				return &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+			}
+			e.ptrSeen[ptr] = struct{}{}
+			defer delete(e.ptrSeen, ptr)
+		}
+		err := elemEnc(e, v.Elem(), opts)
+		e.ptrLevel--
+		return err
+	}
+}
+
+func condAddrEncoder(canAddrEnc, elseEnc encoderFunc) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) error {
+		if v.CanAddr() {
+			return canAddrEnc(e, v, opts)
+		}
+		return elseEnc(e, v, opts)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s as a JSON string with the escaping of
+// encoding/json: invalid UTF-8 is replaced by U+FFFD, U+2028 and U+2029 are escaped
+// and so are <, > and & when escapeHTML is set.
+func appendString(dst []byte, src string, escapeHTML bool) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(src); {
+		if b := src[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && (!escapeHTML || (b != '<' && b != '>' && b != '&')) {
+				i++
+				continue
+			}
+			dst = append(dst, src[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(src[i:])
+		if r == utf8.RuneError && n == 1 {
+			dst = append(dst, src[start:i]...)
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+			i += n
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, src[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += n
+			start = i
+			continue
+		}
+		i += n
+	}
+	dst = append(dst, src[start:]...)
+	dst = append(dst, '"')
+	return dst
+}
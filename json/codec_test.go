@@ -0,0 +1,270 @@
+package json
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecDuplicateFieldName(t *testing.T) {
+	// 与 TestDuplicateFieldName 的结论一致
+	{
+		result, err := NewCodec[C]().Marshal(C{A: A{ID: "a"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"a"}`, string(result))
+	}
+	{
+		result, err := NewCodec[D]().Marshal(D{A: A{ID: "a"}, B: B{ID: "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{}`, string(result))
+	}
+	{
+		result, err := NewCodec[E]().Marshal(E{A: A{ID: "a"}, Identifiable: &B{ID: "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"a","Identifiable":{"id":"b"}}`, string(result))
+	}
+	{
+		result, err := NewCodec[G]().Marshal(G{A: A{ID: "a"}, F: F{Name: "f"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"a","name":"f"}`, string(result))
+	}
+	{
+		result, err := NewCodec[H]().Marshal(H{F: F{Name: "f"}, Identifiable: &B{ID: "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"f","Identifiable":{"id":"b"}}`, string(result))
+	}
+}
+
+type codecPtrMarshaler struct {
+	V int
+}
+
+func (m *codecPtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(` { "ptr" : true } `), nil
+}
+
+type codecKey struct {
+	A, B string
+}
+
+func (k codecKey) MarshalText() ([]byte, error) {
+	return []byte(k.A + "<" + k.B), nil
+}
+
+type codecNode struct {
+	Name     string       `json:"name"`
+	Children []*codecNode `json:"children,omitempty"`
+}
+
+type codecHot struct {
+	*maskModel
+	Name     string              `json:"name"`
+	Age      int                 `json:"age,string"`
+	Score    *float64            `json:"score,string"`
+	Active   bool                `json:"active,omitempty"`
+	Ratio    float32             `json:"ratio"`
+	Tags     []string            `json:"tags"`
+	Raw      []byte              `json:"raw"`
+	Attrs    map[string]any      `json:"attrs,omitempty"`
+	ByID     map[int64]string    `json:"by_id"`
+	ByKey    map[codecKey]int    `json:"by_key"`
+	Number   json.Number         `json:"number"`
+	Message  json.RawMessage     `json:"message"`
+	Custom   codecPtrMarshaler   `json:"custom"`
+	Customs  []codecPtrMarshaler `json:"customs"`
+	IP       net.IP              `json:"ip"`
+	Grid     [2][2]uint8         `json:"grid"`
+	Any      any                 `json:"any"`
+	Tree     *codecNode          `json:"tree"`
+	skipped  string
+	Ignored  string `json:"-"`
+	Dash     string `json:"-,"`
+	unexport int
+}
+
+func assertCodecMatches[T any](t *testing.T, v T) {
+	t.Helper()
+	expected, err := json.Marshal(v)
+	require.NoError(t, err)
+	actual, err := NewCodec[T]().Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestCodecMatchesEncodingJSON(t *testing.T) {
+	score := 9.5
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	hot := codecHot{
+		maskModel: &maskModel{ID: 7, CreatedAt: createdAt},
+		Name:      "<a & b>  \x00\x1f\t\"\\ \xff é 😀",
+		Age:       42,
+		Score:     &score,
+		Ratio:     0.1,
+		Tags:      []string{"x", "y"},
+		Raw:       []byte("hello"),
+		Attrs:     map[string]any{"b": 1.5e21, "a": 1e-7, "c": []any{nil, true, "s"}},
+		ByID:      map[int64]string{10: "ten", -2: "minus two", 3: "three"},
+		ByKey:     map[codecKey]int{{"b", "c"}: 2, {"a", "z"}: 1},
+		Number:    "12.5e3",
+		Message:   json.RawMessage(`{ "b" : [1, 2], "a":"<"}`),
+		Customs:   []codecPtrMarshaler{{1}, {2}},
+		IP:        net.IPv4(127, 0, 0, 1),
+		Grid:      [2][2]uint8{{1, 2}, {3, 4}},
+		Any:       &F{Name: "f"},
+		Tree:      &codecNode{Name: "root", Children: []*codecNode{{Name: "leaf"}}},
+		skipped:   "x",
+		Ignored:   "x",
+		Dash:      "dash",
+	}
+	assertCodecMatches(t, hot)
+	// 指针接收者的 MarshalJSON 只在可寻址时生效
+	assertCodecMatches(t, &hot)
+	assertCodecMatches(t, codecHot{})
+	assertCodecMatches(t, &codecHot{})
+	assertCodecMatches(t, (*codecHot)(nil))
+	assertCodecMatches(t, codecPtrMarshaler{})
+
+	assertCodecMatches[any](t, nil)
+	assertCodecMatches[any](t, map[string]any{"z": []int{1}, "a": map[string]float32{"f": 3.4e-8}})
+	assertCodecMatches(t, []float64{0, -0.0, 1, -1.5, 1e20, 1e21, 1e-6, 1e-7, 123456789.125, math.MaxFloat64})
+	assertCodecMatches(t, []float32{0, 1e20, 1e21, 1e-6, 1e-7, 3.4e38, 0.1})
+	assertCodecMatches(t, map[uint8]bool{1: true})
+	assertCodecMatches(t, createdAt)
+	assertCodecMatches(t, map[string]time.Time{"t": createdAt.In(time.FixedZone("", -(5*60*60 + 30*60)))})
+	assertCodecMatches(t, json.RawMessage("[\"a b\\\" <\u2028\",\n\t{ } ]"))
+	assertCodecMatches(t, []byte(nil))
+	assertCodecMatches(t, [0]int{})
+}
+
+func TestCodecErrors(t *testing.T) {
+	_, err := NewCodec[float64]().Marshal(math.NaN())
+	var valueErr *json.UnsupportedValueError
+	require.ErrorAs(t, err, &valueErr)
+
+	_, err = NewCodec[map[string]any]().Marshal(map[string]any{"ch": make(chan int)})
+	var typeErr *json.UnsupportedTypeError
+	require.ErrorAs(t, err, &typeErr)
+
+	_, err = NewCodec[json.Number]().Marshal("1 ")
+	require.ErrorAs(t, err, &valueErr)
+
+	_, err = NewCodec[json.RawMessage]().Marshal(json.RawMessage(`{`))
+	var marshalerErr *json.MarshalerError
+	require.ErrorAs(t, err, &marshalerErr)
+
+	_, err = NewCodec[time.Time]().Marshal(time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.ErrorAs(t, err, &marshalerErr)
+
+	// 指针循环不会栈溢出
+	type cycle struct {
+		Next *cycle
+	}
+	c := &cycle{}
+	c.Next = c
+	_, err = NewCodec[*cycle]().Marshal(c)
+	require.ErrorAs(t, err, &valueErr)
+
+	// 包含自身的 map 和 slice 同样返回错误
+	m := map[string]any{}
+	m["self"] = m
+	_, err = NewCodec[map[string]any]().Marshal(m)
+	require.ErrorAs(t, err, &valueErr)
+	assert.Contains(t, valueErr.Str, "encountered a cycle via map[string]interface {}")
+
+	s := []any{nil}
+	s[0] = s
+	_, err = NewCodec[[]any]().Marshal(s)
+	require.ErrorAs(t, err, &valueErr)
+	assert.Contains(t, valueErr.Str, "encountered a cycle via []interface {}")
+}
+
+func TestCodecAppendAndUnmarshal(t *testing.T) {
+	codec := NewCodec[F]()
+	buf, err := codec.Append([]byte("prefix:"), F{Name: "f"})
+	require.NoError(t, err)
+	assert.Equal(t, `prefix:{"name":"f"}`, string(buf))
+
+	f, err := codec.Unmarshal([]byte(`{"name":"g"}`))
+	require.NoError(t, err)
+	assert.Equal(t, F{Name: "g"}, f)
+}
+
+func benchmarkUser() *maskUser {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &maskUser{
+		maskModel: maskModel{ID: 1, CreatedAt: createdAt},
+		Name:      "alice",
+		Password:  "secret",
+		Age:       30,
+		Notes:     "vip <customer>",
+		Addresses: []*maskAddress{
+			{maskModel: maskModel{ID: 2, CreatedAt: createdAt}, AddressLine: "1 Main St", UserID: 1},
+			{maskModel: maskModel{ID: 3, CreatedAt: createdAt}, AddressLine: "2 Side St", UserID: 1},
+		},
+		Meta: map[string]any{"plan": "pro", "seats": 5},
+	}
+}
+
+func BenchmarkCodecMarshal(b *testing.B) {
+	codec := NewCodec[*maskUser]()
+	user := benchmarkUser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Marshal(user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodecAppend(b *testing.B) {
+	codec := NewCodec[*maskUser]()
+	user := benchmarkUser()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = codec.Append(buf[:0], user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStdMarshal(b *testing.B) {
+	user := benchmarkUser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodecMarshalParallel(b *testing.B) {
+	codec := NewCodec[*maskUser]()
+	user := benchmarkUser()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := codec.Marshal(user); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStdMarshalParallel(b *testing.B) {
+	user := benchmarkUser()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := json.Marshal(user); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,283 @@
+// Package structfield resolves the JSON field set of a struct type with the
+// same rules encoding/json uses, over an abstract type model so that it can
+// be driven both by reflect (at runtime) and by go/types (in analyzers).
+package structfield
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kind is the coarse kind of a Type as far as field resolution cares.
+type Kind int
+
+const (
+	Other Kind = iota
+	Struct
+	Pointer
+	Interface
+)
+
+// Type is the minimal view of a Go type needed to resolve JSON fields.
+type Type interface {
+	Kind() Kind
+	// Elem returns the element type of a Pointer.
+	Elem() Type
+	// Named reports whether the type has a name (as opposed to a type literal).
+	Named() bool
+	// NumField and Field describe the fields of a Struct.
+	NumField() int
+	Field(i int) Field
+	// Key identifies the type, identical types must return the same
+	// comparable key.
+	Key() any
+}
+
+// Field is a struct field of a Type.
+type Field struct {
+	Name      string
+	Tag       reflect.StructTag
+	Anonymous bool
+	Exported  bool
+	Type      Type
+}
+
+// Resolved is a field that ends up in the JSON encoding of a struct.
+type Resolved struct {
+	Name      string
+	Tagged    bool
+	OmitEmpty bool
+	// Quoted reports the ",string" option, whether it applies depends on
+	// the field kind and is left to the caller.
+	Quoted bool
+	// Tag is the full struct tag, for callers honoring their own options.
+	Tag reflect.StructTag
+	// Index is the index sequence for reflect.Value.FieldByIndex.
+	Index []int
+	// Path is the Go field names leading to the field, e.g. ["A", "ID"].
+	Path []string
+	Type Type
+}
+
+// Dropped describes a JSON name whose candidate fields were discarded.
+// When Winner is nil every candidate was dropped because none dominated.
+type Dropped struct {
+	Name       string
+	Winner     *Resolved
+	Candidates []Resolved
+}
+
+// Result is the outcome of resolving a struct type.
+type Result struct {
+	Fields  []Resolved
+	Dropped []Dropped
+	// Interfaces are embedded interface fields, which encoding/json never
+	// promotes and encodes as a nested object under the Go type name.
+	Interfaces []Resolved
+}
+
+// Resolve computes the JSON fields of the struct type t, following
+// typeFields in encoding/json.
+func Resolve(t Type) Result {
+	type queued struct {
+		typ   Type
+		index []int
+		path  []string
+	}
+
+	var (
+		current []queued
+		next    = []queued{{typ: t}}
+		count   = map[any]int{}
+		nextCnt = map[any]int{}
+		visited = map[any]bool{}
+		fields  []Resolved
+		ifaces  []Resolved
+	)
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCnt = nextCnt, map[any]int{}
+
+		for _, f := range current {
+			if visited[f.typ.Key()] {
+				continue
+			}
+			visited[f.typ.Key()] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.Exported && ft.Kind() != Struct {
+						continue
+					}
+				} else if !sf.Exported {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := append(append([]int(nil), f.index...), i)
+				path := append(append([]string(nil), f.path...), sf.Name)
+
+				ft := sf.Type
+				if !ft.Named() && ft.Kind() == Pointer {
+					ft = ft.Elem()
+				}
+
+				if name != "" || !sf.Anonymous || ft.Kind() != Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					field := Resolved{
+						Name:      name,
+						Tagged:    tagged,
+						OmitEmpty: hasOption(opts, "omitempty"),
+						Quoted:    hasOption(opts, "string"),
+						Tag:       sf.Tag,
+						Index:     index,
+						Path:      path,
+						Type:      sf.Type,
+					}
+					fields = append(fields, field)
+					if sf.Anonymous && !tagged && ft.Kind() == Interface {
+						ifaces = append(ifaces, field)
+					}
+					if count[f.typ.Key()] > 1 {
+						// The embedding struct was reached more than once at
+						// this depth, add a copy so the duplicate annihilates.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCnt[ft.Key()]++
+				if nextCnt[ft.Key()] == 1 {
+					next = append(next, queued{typ: ft, index: index, path: path})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		x := fields
+		if x[i].Name != x[j].Name {
+			return x[i].Name < x[j].Name
+		}
+		if len(x[i].Index) != len(x[j].Index) {
+			return len(x[i].Index) < len(x[j].Index)
+		}
+		if x[i].Tagged != x[j].Tagged {
+			return x[i].Tagged
+		}
+		return lessIndex(x[i].Index, x[j].Index)
+	})
+
+	var res Result
+	res.Interfaces = ifaces
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].Name != fi.Name {
+				break
+			}
+		}
+		group := fields[i : i+advance]
+		if advance == 1 {
+			res.Fields = append(res.Fields, fi)
+			continue
+		}
+		dominant, ok := dominantField(group)
+		if ok {
+			res.Fields = append(res.Fields, dominant)
+		}
+		// Only fields at the dominant depth compete, deeper ones are
+		// ordinary shadowing just like Go's own field promotion.
+		var rivals []Resolved
+		for _, g := range group {
+			if len(g.Index) == len(group[0].Index) {
+				rivals = append(rivals, g)
+			}
+		}
+		if len(rivals) > 1 {
+			d := Dropped{Name: fi.Name, Candidates: rivals}
+			if ok {
+				d.Winner = &dominant
+			}
+			res.Dropped = append(res.Dropped, d)
+		}
+	}
+
+	sort.Slice(res.Fields, func(i, j int) bool {
+		return lessIndex(res.Fields[i].Index, res.Fields[j].Index)
+	})
+	return res
+}
+
+// dominantField mirrors encoding/json: the first field is at minimal depth
+// and, if tagged, sorts first; a tie at that depth means nothing dominates.
+func dominantField(fields []Resolved) (Resolved, bool) {
+	if len(fields) > 1 && len(fields[0].Index) == len(fields[1].Index) && fields[0].Tagged == fields[1].Tagged {
+		return Resolved{}, false
+	}
+	return fields[0], true
+}
+
+func lessIndex(a, b []int) bool {
+	for k, xik := range a {
+		if k >= len(b) {
+			return false
+		}
+		if xik != b[k] {
+			return xik < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// HasOption reports whether the json tag of a field has the option name,
+// e.g. HasOption(f.Tag, "redact") for `json:"password,redact"`.
+func HasOption(tag reflect.StructTag, name string) bool {
+	_, opts, _ := strings.Cut(tag.Get("json"), ",")
+	return hasOption(opts, name)
+}
+
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+package json
+
+import "encoding/json"
+
+// Unmarshal 这个范型方法可以直接规避掉 TestUnmarshal 里展示的问题，因为这里强制取了一次地址，并且即使 T 为 iface ，你也无法为其指定类型，最终结果符合直觉。
+// 但是它只能是反序列化到一个空的结构体上
+func Unmarshal[T any](data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return v, err
+	}
+	return v, nil
+}
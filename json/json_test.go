@@ -143,17 +143,6 @@ func TestUnmarshal(t *testing.T) {
 	// 2. 如果是通过 iface hold 的话，需要确保 hold 的不能是 not-ptr / nil-ptr ，否则会丢失具体类型
 }
 
-// 这个范型方法可以直接规避掉上述问题，因为这里强制取了一次地址，并且即使 T 为 iface ，你也无法为其指定类型，最终结果符合直觉。
-// 但是它只能是反序列化到一个空的结构体上
-func Unmarshal[T any](data []byte) (T, error) {
-	var v T
-	err := json.Unmarshal(data, &v)
-	if err != nil {
-		return v, err
-	}
-	return v, nil
-}
-
 func TestUnmarshalGeneric(t *testing.T) {
 	type Person struct {
 		Name string `json:"name"`
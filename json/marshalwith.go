@@ -0,0 +1,360 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+// Redacted replaces the value of fields tagged with the redact option.
+const Redacted = "***"
+
+// MarshalOptions controls MarshalWith.
+type MarshalOptions struct {
+	// Fields is a field mask of dotted JSON names such as
+	// "addresses.address_line", see ParseFieldMask. Selecting a field
+	// selects its whole value, an empty mask selects everything. Masks apply
+	// through pointers, slices and arrays to the elements, the keys of maps
+	// are selected like the names of fields.
+	Fields []string
+	// Role selects the view, fields tagged `view:"admin,support"` are only
+	// marshaled for the listed roles. Fields without a view tag are visible
+	// to every role.
+	Role string
+}
+
+// ParseFieldMask parses a comma separated field mask such as
+// "id,name,addresses.address_line".
+func ParseFieldMask(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// MarshalWith is like json.Marshal but applies opts: fields outside the
+// field mask or not visible to the role are left out, and fields tagged
+// `json:"...,redact"` are replaced by Redacted.
+//
+// Field resolution, omitempty and the string option follow encoding/json,
+// values with their own MarshalJSON or MarshalText are marshaled whole.
+func MarshalWith(v any, opts MarshalOptions) ([]byte, error) {
+	m := &maskedEncoder{role: opts.Role}
+	var mask fieldMask
+	if len(opts.Fields) > 0 {
+		mask = fieldMask{}
+		for _, f := range opts.Fields {
+			mask.add(strings.Split(f, "."))
+		}
+	}
+	if err := m.encode(reflect.ValueOf(v), mask); err != nil {
+		return nil, err
+	}
+	return m.buf.Bytes(), nil
+}
+
+// fieldMask is a tree of selected JSON names, a nil mask selects everything.
+type fieldMask map[string]fieldMask
+
+func (m fieldMask) add(path []string) {
+	child, ok := m[path[0]]
+	if len(path) == 1 {
+		m[path[0]] = nil // the whole value wins over sub selections
+		return
+	}
+	if ok && child == nil {
+		return
+	}
+	if child == nil {
+		child = fieldMask{}
+		m[path[0]] = child
+	}
+	child.add(path[1:])
+}
+
+// selects reports whether name is selected and returns the mask for its value.
+func (m fieldMask) selects(name string) (fieldMask, bool) {
+	if m == nil {
+		return nil, true
+	}
+	child, ok := m[name]
+	return child, ok
+}
+
+type maskedEncoder struct {
+	buf      bytes.Buffer
+	role     string
+	ptrLevel uint
+	ptrSeen  map[any]struct{}
+}
+
+func (m *maskedEncoder) marshal(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.buf.Write(data)
+	return nil
+}
+
+func (m *maskedEncoder) encode(v reflect.Value, mask fieldMask) error {
+	if !v.IsValid() {
+		m.buf.WriteString("null")
+		return nil
+	}
+	if marshaler, ok := customMarshaler(v); ok {
+		return m.marshal(marshaler)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			m.buf.WriteString("null")
+			return nil
+		}
+		return m.encode(v.Elem(), mask)
+	case reflect.Pointer:
+		if v.IsNil() {
+			m.buf.WriteString("null")
+			return nil
+		}
+		return m.visit(v, cycleKey{ptr: v.UnsafePointer(), typ: v.Type()}, func() error {
+			return m.encode(v.Elem(), mask)
+		})
+	case reflect.Struct:
+		return m.encodeStruct(v, mask)
+	case reflect.Map:
+		return m.visit(v, cycleKey{ptr: v.UnsafePointer()}, func() error {
+			return m.encodeMap(v, mask)
+		})
+	case reflect.Slice:
+		if v.IsNil() {
+			m.buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return m.marshal(v.Interface())
+		}
+		return m.visit(v, cycleKey{ptr: v.UnsafePointer(), len: v.Len()}, func() error {
+			return m.encodeArray(v, mask)
+		})
+	case reflect.Array:
+		return m.encodeArray(v, mask)
+	default:
+		return m.marshal(v.Interface())
+	}
+}
+
+// cycleKey identifies a pointer, map or slice value met while encoding.
+type cycleKey struct {
+	ptr any // always an unsafe.Pointer, but avoids a dependency on package unsafe
+	typ reflect.Type
+	len int
+}
+
+// visit runs encode for the pointer, map or slice v and, past
+// startDetectingCyclesAfter nested levels, fails when v is already being
+// encoded, like the Codec.
+func (m *maskedEncoder) visit(v reflect.Value, key cycleKey, encode func() error) error {
+	if m.ptrLevel++; m.ptrLevel > startDetectingCyclesAfter {
+		if _, ok := m.ptrSeen[key]; ok {
+			return &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+		}
+		if m.ptrSeen == nil {
+			m.ptrSeen = map[any]struct{}{}
+		}
+		m.ptrSeen[key] = struct{}{}
+		defer delete(m.ptrSeen, key)
+	}
+	err := encode()
+	m.ptrLevel--
+	return err
+}
+
+func (m *maskedEncoder) encodeArray(v reflect.Value, mask fieldMask) error {
+	m.buf.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			m.buf.WriteByte(',')
+		}
+		if err := m.encode(v.Index(i), mask); err != nil {
+			return err
+		}
+	}
+	m.buf.WriteByte(']')
+	return nil
+}
+
+func (m *maskedEncoder) encodeStruct(v reflect.Value, mask fieldMask) error {
+	m.buf.WriteByte('{')
+	first := true
+	for _, f := range structfield.Resolve(reflectType{v.Type()}).Fields {
+		child, ok := mask.selects(f.Name)
+		if !ok || !m.visible(f.Tag) {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.Index)
+		if !ok || (f.OmitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if !first {
+			m.buf.WriteByte(',')
+		}
+		first = false
+		m.writeString(f.Name)
+		m.buf.WriteByte(':')
+
+		switch {
+		case structfield.HasOption(f.Tag, "redact"):
+			m.writeString(Redacted)
+		case f.Quoted && isQuotable(fv.Type()) && !(fv.Kind() == reflect.Pointer && fv.IsNil()):
+			data, err := json.Marshal(fv.Interface())
+			if err != nil {
+				return err
+			}
+			m.writeString(string(data))
+		default:
+			if err := m.encode(fv, child); err != nil {
+				return errors.Wrapf(err, "field %s", strings.Join(f.Path, "."))
+			}
+		}
+	}
+	m.buf.WriteByte('}')
+	return nil
+}
+
+func (m *maskedEncoder) encodeMap(v reflect.Value, mask fieldMask) error {
+	if v.IsNil() {
+		m.buf.WriteString("null")
+		return nil
+	}
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKeyString(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	m.buf.WriteByte('{')
+	first := true
+	for _, e := range entries {
+		child, ok := mask.selects(e.key)
+		if !ok {
+			continue
+		}
+		if !first {
+			m.buf.WriteByte(',')
+		}
+		first = false
+		m.writeString(e.key)
+		m.buf.WriteByte(':')
+		if err := m.encode(e.value, child); err != nil {
+			return err
+		}
+	}
+	m.buf.WriteByte('}')
+	return nil
+}
+
+func (m *maskedEncoder) visible(tag reflect.StructTag) bool {
+	roles, ok := tag.Lookup("view")
+	if !ok {
+		return true
+	}
+	for _, r := range strings.Split(roles, ",") {
+		if strings.TrimSpace(r) == m.role && m.role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *maskedEncoder) writeString(s string) {
+	data, _ := json.Marshal(s)
+	m.buf.Write(data)
+}
+
+// customMarshaler returns the value to hand to json.Marshal when v has its
+// own MarshalJSON or MarshalText, honoring pointer receivers only when v is
+// addressable like encoding/json does.
+func customMarshaler(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, false
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface(), true
+	}
+	if v.CanAddr() && (reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but reports false instead
+// of panicking on a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type maskModel struct {
+	ID        uint       `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at" view:"admin"`
+}
+
+type maskAddress struct {
+	maskModel
+	AddressLine string `json:"address_line"`
+	UserID      uint   `json:"user_id"`
+}
+
+type maskUser struct {
+	maskModel
+	Name      string         `json:"name"`
+	Password  string         `json:"password,redact"`
+	Token     string         `json:"token,omitempty,redact"`
+	Age       int            `json:"age,string"`
+	Notes     string         `json:"notes" view:"admin,support"`
+	Addresses []*maskAddress `json:"addresses"`
+	Meta      map[string]any `json:"meta,omitempty"`
+}
+
+func TestMarshalWith(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &maskUser{
+		maskModel: maskModel{ID: 1, CreatedAt: createdAt, DeletedAt: &createdAt},
+		Name:      "alice",
+		Password:  "secret",
+		Age:       30,
+		Notes:     "vip",
+		Addresses: []*maskAddress{
+			{maskModel: maskModel{ID: 10, CreatedAt: createdAt}, AddressLine: "123 Street", UserID: 1},
+		},
+		Meta: map[string]any{"b": 2, "a": map[string]any{"x": 1, "y": 2}},
+	}
+
+	{
+		// 不给任何参数时，除了 redact 和 view 外和 json.Marshal 一致
+		result, err := MarshalWith(user, MarshalOptions{})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"id": 1, "created_at": "2024-01-02T03:04:05Z",
+			"name": "alice", "password": "***", "age": "30",
+			"addresses": [{"id": 10, "created_at": "2024-01-02T03:04:05Z", "address_line": "123 Street", "user_id": 1}],
+			"meta": {"a": {"x": 1, "y": 2}, "b": 2}
+		}`, string(result))
+
+		// 输出的字段顺序和 json.Marshal 一致
+		g := G{A: A{ID: "a"}, F: F{Name: "f"}}
+		expected, err := json.Marshal(g)
+		require.NoError(t, err)
+		result, err = MarshalWith(g, MarshalOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(result))
+	}
+	{
+		result, err := MarshalWith(user, MarshalOptions{Role: "admin"})
+		require.NoError(t, err)
+		assert.Contains(t, string(result), `"deleted_at":"2024-01-02T03:04:05Z"`)
+		assert.Contains(t, string(result), `"notes":"vip"`)
+		assert.Contains(t, string(result), `"deleted_at":null`) // addresses 里的 DeletedAt
+
+		result, err = MarshalWith(user, MarshalOptions{Role: "support"})
+		require.NoError(t, err)
+		assert.NotContains(t, string(result), `deleted_at`)
+		assert.Contains(t, string(result), `"notes":"vip"`)
+	}
+	{
+		result, err := MarshalWith(user, MarshalOptions{
+			Fields: ParseFieldMask("id, name,addresses.address_line,meta.a.x,deleted_at,password"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":1,"name":"alice","password":"***","addresses":[{"address_line":"123 Street"}],"meta":{"a":{"x":1}}}`, string(result))
+
+		// 选中整个字段时，会覆盖其子字段的选择
+		result, err = MarshalWith([]*maskUser{user, nil}, MarshalOptions{Fields: []string{"meta.a.x", "meta", "unknown.field"}})
+		require.NoError(t, err)
+		assert.Equal(t, `[{"meta":{"a":{"x":1,"y":2},"b":2}},null]`, string(result))
+
+		// map 的 key 和字段名一样被选择，而不是把掩码应用到每个值
+		byKind := map[string]*maskAddress{"home": user.Addresses[0], "work": user.Addresses[0]}
+		result, err = MarshalWith(byKind, MarshalOptions{Fields: []string{"home.address_line"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"home":{"address_line":"123 Street"}}`, string(result))
+		result, err = MarshalWith(byKind, MarshalOptions{Fields: []string{"address_line"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{}`, string(result))
+	}
+	{
+		// embed 的指针为 nil 时，其字段不会输出，和 encoding/json 一致
+		type outer struct {
+			*maskModel
+			Name string `json:"name"`
+		}
+		result, err := MarshalWith(outer{Name: "n"}, MarshalOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"n"}`, string(result))
+	}
+	{
+		// string 选项只穿过未命名的指针，和 encoding/json 一致
+		type namedPtr *int
+		n := 1
+		v := struct {
+			A *int     `json:"a,string"`
+			B namedPtr `json:"b,string"`
+			C **int    `json:"c,string"`
+		}{&n, &n, nil}
+		result, err := MarshalWith(v, MarshalOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"1","b":1,"c":null}`, string(result))
+		result, err = NewCodec[any]().Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"1","b":1,"c":null}`, string(result))
+	}
+	{
+		// 指针、map 和 slice 的循环返回错误，而不是栈溢出
+		type cycle struct {
+			Next *cycle `json:"next"`
+		}
+		c := &cycle{}
+		c.Next = c
+		var valueErr *json.UnsupportedValueError
+		_, err := MarshalWith(c, MarshalOptions{})
+		require.ErrorAs(t, err, &valueErr)
+
+		m := map[string]any{}
+		m["self"] = m
+		_, err = MarshalWith(m, MarshalOptions{})
+		require.ErrorAs(t, err, &valueErr)
+
+		s := []any{nil}
+		s[0] = s
+		_, err = MarshalWith(s, MarshalOptions{})
+		require.ErrorAs(t, err, &valueErr)
+	}
+}
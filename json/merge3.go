@@ -0,0 +1,206 @@
+package json
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MergeConflict is a location changed differently by both sides of a
+// three-way merge. Merge3 resolves it by keeping ours.
+type MergeConflict struct {
+	// Path is the JSON Pointer of the location. Array elements matched by
+	// identity are addressed by their index in ours, or in theirs when ours
+	// removed them.
+	Path string
+	// Base, Ours and Theirs are the values on each side, nil when absent.
+	Base, Ours, Theirs json.RawMessage
+}
+
+// MergeOptions controls Merge3With.
+type MergeOptions struct {
+	// ArrayKey is the member identifying the objects of an array, arrays of
+	// objects that all have a distinct scalar ArrayKey are merged element by
+	// element. Other arrays are merged as a whole. Empty disables it.
+	ArrayKey string
+}
+
+// Merge3 is Merge3With with ArrayKey "id".
+func Merge3(base, ours, theirs []byte) (merged []byte, conflicts []MergeConflict, err error) {
+	return Merge3With(base, ours, theirs, MergeOptions{ArrayKey: "id"})
+}
+
+// Merge3With merges the changes made from base to ours and from base to
+// theirs. Objects are merged key by key, a key removed on one side and left
+// untouched on the other is removed. Changes that conflict are reported in
+// path order and resolved by keeping ours. Object members of the result are
+// sorted by name.
+func Merge3With(base, ours, theirs []byte, opts MergeOptions) (merged []byte, conflicts []MergeConflict, err error) {
+	var docs [3]any
+	for i, data := range [][]byte{base, ours, theirs} {
+		if docs[i], err = decodeNumbers(data); err != nil {
+			return nil, nil, errors.Wrapf(err, "json: merge: %s", [...]string{"base", "ours", "theirs"}[i])
+		}
+	}
+	m := &merger{opts: opts}
+	result, _ := m.merge(docs[0], docs[1], docs[2], "")
+	merged, err = json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, m.conflicts, nil
+}
+
+// absent marks a missing member or element.
+type absentValue struct{}
+
+var absent any = absentValue{}
+
+type merger struct {
+	opts      MergeOptions
+	conflicts []MergeConflict
+}
+
+func (m *merger) merge(base, ours, theirs any, path string) (any, bool) {
+	switch {
+	case equalMerge(ours, theirs), equalMerge(base, theirs):
+		return ours, ours != absent
+	case equalMerge(base, ours):
+		return theirs, theirs != absent
+	}
+
+	if o, ok := ours.(map[string]any); ok {
+		if t, ok := theirs.(map[string]any); ok {
+			b, _ := base.(map[string]any)
+			return m.mergeObjects(b, o, t, path), true
+		}
+	}
+	if o, ok := ours.([]any); ok {
+		if t, ok := theirs.([]any); ok {
+			b, _ := base.([]any)
+			if merged, ok := m.mergeArrays(b, o, t, path); ok {
+				return merged, true
+			}
+		}
+	}
+
+	m.conflicts = append(m.conflicts, MergeConflict{
+		Path:   path,
+		Base:   rawOrNil(base),
+		Ours:   rawOrNil(ours),
+		Theirs: rawOrNil(theirs),
+	})
+	return ours, ours != absent
+}
+
+func (m *merger) mergeObjects(base, ours, theirs map[string]any, path string) map[string]any {
+	merged := map[string]any{}
+	union := map[string]any{}
+	for _, obj := range []map[string]any{base, ours, theirs} {
+		for k := range obj {
+			union[k] = nil
+		}
+	}
+	for _, k := range sortedKeys(union) {
+		v, ok := m.merge(member(base, k), member(ours, k), member(theirs, k), path+"/"+escapePointerToken(k))
+		if ok {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// mergeArrays merges arrays of objects by identity, keeping the order of
+// ours and appending elements only added by theirs.
+func (m *merger) mergeArrays(base, ours, theirs []any, path string) ([]any, bool) {
+	if m.opts.ArrayKey == "" {
+		return nil, false
+	}
+	baseIdx, ok1 := m.identities(base)
+	oursIdx, ok2 := m.identities(ours)
+	theirsIdx, ok3 := m.identities(theirs)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, false
+	}
+
+	var order []string
+	seen := map[string]bool{}
+	for _, arr := range [][]any{ours, theirs, base} {
+		for _, e := range arr {
+			id := identity(e, m.opts.ArrayKey)
+			if !seen[id] {
+				seen[id] = true
+				order = append(order, id)
+			}
+		}
+	}
+
+	merged := []any{}
+	for _, id := range order {
+		at := func(arr []any, idx map[string]int) any {
+			if i, ok := idx[id]; ok {
+				return arr[i]
+			}
+			return absent
+		}
+		i, ok := oursIdx[id]
+		if !ok {
+			i = theirsIdx[id]
+		}
+		v, ok := m.merge(at(base, baseIdx), at(ours, oursIdx), at(theirs, theirsIdx), path+"/"+strconv.Itoa(i))
+		if ok {
+			merged = append(merged, v)
+		}
+	}
+	return merged, true
+}
+
+// identities indexes the elements of arr by their identity key, it fails
+// when an element is not an object, lacks the key or repeats it.
+func (m *merger) identities(arr []any) (map[string]int, bool) {
+	idx := make(map[string]int, len(arr))
+	for i, e := range arr {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		switch obj[m.opts.ArrayKey].(type) {
+		case string, json.Number, bool:
+		default:
+			return nil, false
+		}
+		id := identity(e, m.opts.ArrayKey)
+		if _, dup := idx[id]; dup {
+			return nil, false
+		}
+		idx[id] = i
+	}
+	return idx, true
+}
+
+func identity(e any, key string) string {
+	return marshalString(e.(map[string]any)[key])
+}
+
+func member(obj map[string]any, k string) any {
+	if v, ok := obj[k]; ok {
+		return v
+	}
+	return absent
+}
+
+func equalMerge(a, b any) bool {
+	if a == absent || b == absent {
+		return a == b
+	}
+	return equalJSON(a, b)
+}
+
+func rawOrNil(v any) json.RawMessage {
+	if v == absent {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,85 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3(t *testing.T) {
+	base := `{
+		"name": "cfg", "version": 1, "removed": true, "big": 9007199254740993,
+		"servers": [{"id": 1, "host": "a", "port": 80}, {"id": 2, "host": "b"}, {"id": 3, "host": "c"}],
+		"tags": ["x", "y"],
+		"limits": {"cpu": 1, "mem": 2}
+	}`
+	ours := `{
+		"name": "cfg", "version": 2, "big": 9007199254740993,
+		"servers": [{"id": 1, "host": "a", "port": 8080}, {"id": 3, "host": "c"}, {"id": 4, "host": "d"}],
+		"tags": ["x", "y", "z"],
+		"limits": {"cpu": 2, "mem": 2}
+	}`
+	theirs := `{
+		"name": "config", "version": 3, "removed": true, "big": 9007199254740993,
+		"servers": [{"id": 1, "host": "aa", "port": 80}, {"id": 2, "host": "b"}, {"id": 3, "host": "cc"}, {"id": 5, "host": "e"}],
+		"tags": ["y"],
+		"limits": {"cpu": 1, "mem": 4, "disk": 8}
+	}`
+
+	merged, conflicts, err := Merge3([]byte(base), []byte(ours), []byte(theirs))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "config", "version": 2, "big": 9007199254740993,
+		"servers": [{"id": 1, "host": "aa", "port": 8080}, {"id": 3, "host": "cc"}, {"id": 4, "host": "d"}, {"id": 5, "host": "e"}],
+		"tags": ["x", "y", "z"],
+		"limits": {"cpu": 2, "mem": 4, "disk": 8}
+	}`, string(merged))
+	assert.Contains(t, string(merged), "9007199254740993")
+	assert.Equal(t, []MergeConflict{
+		{Path: "/tags", Base: json.RawMessage(`["x","y"]`), Ours: json.RawMessage(`["x","y","z"]`), Theirs: json.RawMessage(`["y"]`)},
+		{Path: "/version", Base: json.RawMessage(`1`), Ours: json.RawMessage(`2`), Theirs: json.RawMessage(`3`)},
+	}, conflicts)
+
+	{
+		// 一边删除，另一边修改，也是冲突
+		merged, conflicts, err := Merge3([]byte(`{"a":{"x":1}}`), []byte(`{}`), []byte(`{"a":{"x":2}}`))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(merged))
+		assert.Equal(t, []MergeConflict{{Path: "/a", Base: json.RawMessage(`{"x":1}`), Theirs: json.RawMessage(`{"x":2}`)}}, conflicts)
+	}
+	{
+		// 不使用 identity key 时，数组作为整体合并
+		merged, conflicts, err := Merge3With(
+			[]byte(`[{"id":1,"v":1}]`), []byte(`[{"id":1,"v":2}]`), []byte(`[{"id":1,"v":1},{"id":2}]`),
+			MergeOptions{},
+		)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"id":1,"v":2}]`, string(merged))
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, "", conflicts[0].Path)
+	}
+	{
+		_, _, err := Merge3([]byte(`{}`), []byte(`{`), []byte(`{}`))
+		assert.ErrorContains(t, err, "json: merge: ours")
+	}
+}
+
+func TestMerge3MatchesMergePatch(t *testing.T) {
+	// 没有冲突时，效果等同于把 theirs 相对 base 的 merge patch 应用到 ours 上
+	base := []byte(`{"a": 1, "b": {"c": 2, "d": 3}}`)
+	ours := []byte(`{"a": 2, "b": {"c": 2, "d": 3}}`)
+	theirs := []byte(`{"a": 1, "b": {"c": 2}, "e": 5}`)
+
+	patch, err := jsonpatch.CreateMergePatch(base, theirs)
+	require.NoError(t, err)
+	expected, err := jsonpatch.MergePatch(ours, patch)
+	require.NoError(t, err)
+
+	merged, conflicts, err := Merge3(base, ours, theirs)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.JSONEq(t, string(expected), string(merged))
+}
@@ -0,0 +1,81 @@
+package json
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecimalPolicy controls how UnmarshalNumbers represents non-integer numbers.
+type DecimalPolicy int
+
+const (
+	// DecimalFloat64 decodes decimals as float64, like encoding/json does.
+	DecimalFloat64 DecimalPolicy = iota
+	// DecimalNumber keeps decimals as json.Number, without any loss.
+	DecimalNumber
+)
+
+// UnmarshalNumbers decodes data into map[string]any / []any trees like
+// Unmarshal[any], except that integers are decoded as int64, or *big.Int
+// when they do not fit, so that IDs above 2^53 survive a round trip.
+// Decimals, i.e. numbers with a fraction or exponent, follow policy.
+func UnmarshalNumbers(data []byte, policy DecimalPolicy) (any, error) {
+	v, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	return convertNumbers(v, policy)
+}
+
+func convertNumbers(v any, policy DecimalPolicy) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return convertNumber(v, policy)
+	case map[string]any:
+		for k, e := range v {
+			c, err := convertNumbers(e, policy)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = c
+		}
+		return v, nil
+	case []any:
+		for i, e := range v {
+			c, err := convertNumbers(e, policy)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = c
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+func convertNumber(n json.Number, policy DecimalPolicy) (any, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, errors.Errorf("json: invalid integer %s", s)
+		}
+		return i, nil
+	}
+	if policy == DecimalNumber {
+		return n, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, errors.Wrapf(err, "json: number %s", s)
+	}
+	return f, nil
+}
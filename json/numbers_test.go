@@ -0,0 +1,56 @@
+package json
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalNumbers(t *testing.T) {
+	data := []byte(`{"id": 9007199254740993, "big": 123456789012345678901234567890, "n": -1, "price": 1.10, "exp": 1e3, "list": [1, 2.5]}`)
+
+	{
+		// 对比 TestUnmarshal ，Unmarshal[any] 会把 9007199254740993 变成 9007199254740992
+		v, err := Unmarshal[any](data)
+		require.NoError(t, err)
+		assert.Equal(t, float64(9007199254740992), v.(map[string]any)["id"])
+	}
+
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	{
+		v, err := UnmarshalNumbers(data, DecimalFloat64)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"id":    int64(9007199254740993),
+			"big":   bigInt,
+			"n":     int64(-1),
+			"price": 1.1,
+			"exp":   float64(1000),
+			"list":  []any{int64(1), 2.5},
+		}, v)
+
+		// 再序列化回去也不会丢失精度
+		out, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id": 9007199254740993, "big": 123456789012345678901234567890, "n": -1, "price": 1.1, "exp": 1000, "list": [1, 2.5]}`, string(out))
+	}
+	{
+		v, err := UnmarshalNumbers(data, DecimalNumber)
+		require.NoError(t, err)
+		m := v.(map[string]any)
+		assert.Equal(t, json.Number("1.10"), m["price"])
+		assert.Equal(t, json.Number("1e3"), m["exp"])
+		assert.Equal(t, int64(9007199254740993), m["id"])
+	}
+	{
+		v, err := UnmarshalNumbers([]byte(`42`), DecimalFloat64)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), v)
+
+		_, err = UnmarshalNumbers([]byte(`1e400`), DecimalFloat64)
+		assert.ErrorContains(t, err, "json: number 1e400")
+	}
+}
@@ -0,0 +1,219 @@
+package json
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Pointer is a parsed JSON Pointer (RFC 6901), one unescaped reference token
+// per element. The empty Pointer refers to the whole document.
+type Pointer []string
+
+// ParsePointer parses a JSON Pointer such as "/users/0/name".
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, errors.Errorf("json: invalid pointer %q: must start with /", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, errors.Errorf("json: invalid pointer %q: bad escape in %q", s, token)
+			}
+		}
+		tokens[i] = unescapePointerToken(token)
+	}
+	return Pointer(tokens), nil
+}
+
+// MustParsePointer is like ParsePointer but panics on error.
+func MustParsePointer(s string) Pointer {
+	p, err := ParsePointer(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p Pointer) String() string {
+	var sb strings.Builder
+	for _, token := range p {
+		sb.WriteByte('/')
+		sb.WriteString(escapePointerToken(token))
+	}
+	return sb.String()
+}
+
+// Get returns the value p refers to in the decoded document doc, which is
+// made of map[string]any, []any and scalars as produced by Unmarshal[any].
+func (p Pointer) Get(doc any) (any, error) {
+	v := doc
+	for i, token := range p {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[token]
+			if !ok {
+				return nil, errors.Errorf("json: pointer %s: member %q not found", p[:i+1], token)
+			}
+			v = next
+		case []any:
+			idx, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, errors.Wrapf(err, "json: pointer %s", p[:i+1])
+			}
+			v = node[idx]
+		default:
+			return nil, errors.Errorf("json: pointer %s: cannot index into %s", p[:i+1], typeOf(node))
+		}
+	}
+	return v, nil
+}
+
+// Set sets the value p refers to in doc and returns the resulting document.
+// Object members are added when missing and "-" appends to an array, like
+// the "add" operation of JSON Patch. The parent must exist.
+func (p Pointer) Set(doc any, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	return p.update(doc, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			idx, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node[idx] = value
+			return node, nil
+		default:
+			return nil, errors.Errorf("cannot set member of %s", typeOf(node))
+		}
+	})
+}
+
+// Remove removes the value p refers to from doc and returns the resulting
+// document. Array elements after the removed one are shifted.
+func (p Pointer) Remove(doc any) (any, error) {
+	if len(p) == 0 {
+		return nil, errors.New("json: cannot remove the whole document")
+	}
+	return p.update(doc, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, errors.Errorf("member %q not found", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			idx, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:idx:idx], node[idx+1:]...), nil
+		default:
+			return nil, errors.Errorf("cannot remove member of %s", typeOf(node))
+		}
+	})
+}
+
+// update applies f to the parent of the last token and stores the possibly
+// reallocated parent back into its own parent.
+func (p Pointer) update(doc any, f func(parent any, token string) (any, error)) (any, error) {
+	parentPtr := p[:len(p)-1]
+	parent, err := parentPtr.Get(doc)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := f(parent, p[len(p)-1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "json: pointer %s", p)
+	}
+	if len(parentPtr) == 0 {
+		return updated, nil
+	}
+	return parentPtr.Set(doc, updated)
+}
+
+// GetRaw is like Get but works on an encoded document and returns the
+// encoding of the value. Numbers keep their original precision.
+func (p Pointer) GetRaw(data []byte) ([]byte, error) {
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.Get(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// SetRaw is like Set but works on an encoded document. Object members of the
+// result are sorted by name.
+func (p Pointer) SetRaw(data []byte, value any) ([]byte, error) {
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	if raw, ok := value.(json.RawMessage); ok {
+		if value, err = decodeNumbers(raw); err != nil {
+			return nil, err
+		}
+	}
+	doc, err = p.Set(doc, value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// RemoveRaw is like Remove but works on an encoded document. Object members
+// of the result are sorted by name.
+func (p Pointer) RemoveRaw(data []byte) ([]byte, error) {
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err = p.Remove(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// arrayIndex parses an array index token as RFC 6901 requires: no leading
+// zeros and no sign.
+func arrayIndex(token string, length int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || token[0] == '+' || token[0] == '-' {
+		return 0, errors.Errorf("invalid array index %q", token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, errors.Errorf("invalid array index %q", token)
+	}
+	if idx >= length {
+		return 0, errors.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func escapePointerToken(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func unescapePointerToken(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
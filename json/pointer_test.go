@@ -0,0 +1,100 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointer(t *testing.T) {
+	{
+		// RFC 6901 5
+		doc, err := Unmarshal[any]([]byte(`{
+			"foo": ["bar", "baz"], "": 0, "a/b": 1, "c%d": 2, "e^f": 3,
+			"g|h": 4, "i\\j": 5, "k\"l": 6, " ": 7, "m~n": 8
+		}`))
+		require.NoError(t, err)
+		cases := map[string]any{
+			"/foo/0": "bar",
+			"/":      float64(0),
+			"/a~1b":  float64(1),
+			"/c%d":   float64(2),
+			"/e^f":   float64(3),
+			"/g|h":   float64(4),
+			"/i\\j":  float64(5),
+			"/k\"l":  float64(6),
+			"/ ":     float64(7),
+			"/m~0n":  float64(8),
+		}
+		for s, expected := range cases {
+			p, err := ParsePointer(s)
+			require.NoError(t, err)
+			assert.Equal(t, s, p.String())
+			v, err := p.Get(doc)
+			require.NoError(t, err, s)
+			assert.Equal(t, expected, v, s)
+		}
+		v, err := Pointer{}.Get(doc)
+		require.NoError(t, err)
+		assert.Equal(t, doc, v)
+	}
+	{
+		for _, s := range []string{"foo", "/a~2", "/a~"} {
+			_, err := ParsePointer(s)
+			assert.Error(t, err, s)
+		}
+		doc := map[string]any{"a": []any{1}}
+		for _, s := range []string{"/b", "/a/1", "/a/01", "/a/-", "/a/0/x"} {
+			_, err := MustParsePointer(s).Get(doc)
+			assert.Error(t, err, s)
+		}
+	}
+	{
+		doc := map[string]any{"a": map[string]any{"b": []any{1, 2}}}
+		out, err := MustParsePointer("/a/b/-").Set(doc, 3)
+		require.NoError(t, err)
+		out, err = MustParsePointer("/a/c").Set(out, "c")
+		require.NoError(t, err)
+		out, err = MustParsePointer("/a/b/0").Remove(out)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"a": map[string]any{"b": []any{2, 3}, "c": "c"}}, out)
+
+		_, err = MustParsePointer("/x/y").Set(out, 1)
+		assert.ErrorContains(t, err, `member "x" not found`)
+		_, err = Pointer{}.Remove(out)
+		assert.Error(t, err)
+
+		out, err = Pointer{}.Set(out, "root")
+		require.NoError(t, err)
+		assert.Equal(t, "root", out)
+	}
+}
+
+func TestPointerRaw(t *testing.T) {
+	data := []byte(`{"users": [{"id": 9007199254740993, "name": "alice"}]}`)
+
+	v, err := MustParsePointer("/users/0/id").GetRaw(data)
+	require.NoError(t, err)
+	assert.Equal(t, `9007199254740993`, string(v))
+
+	out, err := MustParsePointer("/users/0/name").SetRaw(data, "bob")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"users": [{"id": 9007199254740993, "name": "bob"}]}`, string(out))
+	assert.Contains(t, string(out), "9007199254740993")
+
+	out, err = MustParsePointer("/users/-").SetRaw(out, []byte(`{"id": 2}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"eyJpZCI6IDJ9"`) // []byte 按 encoding/json 规则是 base64
+
+	out, err = MustParsePointer("/users/1").RemoveRaw(out)
+	require.NoError(t, err)
+	out, err = MustParsePointer("/users/-").SetRaw(out, json.RawMessage(`{"id": 2}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"users": [{"id": 9007199254740993, "name": "bob"}, {"id": 2}]}`, string(out))
+
+	out, err = MustParsePointer("/users/0").RemoveRaw(out)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"users": [{"id": 2}]}`, string(out))
+}
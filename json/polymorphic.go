@@ -0,0 +1,241 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Registry maps discriminator values to the concrete types implementing the
+// interface I, so that values of I can be encoded as a JSON object carrying
+// its discriminator (e.g. {"type":"a","id":"1"}) and decoded back.
+type Registry[I any] struct {
+	key string
+
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+var registries sync.Map // reflect.Type of I -> *Registry[I]
+
+// Polymorphic returns the registry for the interface I, creating it with the
+// given discriminator key on first use. Union[I] and Slice[I] use it.
+//
+// It panics if I is not an interface type or if the registry already exists
+// with a different discriminator key.
+func Polymorphic[I any](key string) *Registry[I] {
+	it := reflect.TypeFor[I]()
+	if it.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("json: Polymorphic: %s is not an interface type", it))
+	}
+	v, _ := registries.LoadOrStore(it, &Registry[I]{
+		key:    key,
+		byName: map[string]reflect.Type{},
+		byType: map[reflect.Type]string{},
+	})
+	r := v.(*Registry[I])
+	if r.key != key {
+		panic(fmt.Sprintf("json: Polymorphic: %s already uses discriminator %q", it, r.key))
+	}
+	return r
+}
+
+func lookupRegistry[I any]() (*Registry[I], error) {
+	it := reflect.TypeFor[I]()
+	v, ok := registries.Load(it)
+	if !ok {
+		return nil, errors.Errorf("json: no polymorphic registry for %s", it)
+	}
+	return v.(*Registry[I]), nil
+}
+
+// Register registers the concrete type T under the discriminator name.
+//
+// It panics if T does not implement I, or if name or T is already registered.
+func Register[T, I any](r *Registry[I], name string) {
+	t := reflect.TypeFor[T]()
+	it := reflect.TypeFor[I]()
+	if !t.Implements(it) {
+		panic(fmt.Sprintf("json: Register: %s does not implement %s", t, it))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("json: Register: %q is already registered as %s", name, prev))
+	}
+	if prev, ok := r.byType[t]; ok {
+		panic(fmt.Sprintf("json: Register: %s is already registered as %q", t, prev))
+	}
+	r.byName[name] = t
+	r.byType[t] = name
+}
+
+// Key returns the discriminator key.
+func (r *Registry[I]) Key() string {
+	return r.key
+}
+
+// Marshal encodes v as a JSON object with the discriminator of its dynamic
+// type as the first member. A nil v is encoded as null.
+func (r *Registry[I]) Marshal(v I) ([]byte, error) {
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.IsNil() {
+		return []byte("null"), nil
+	}
+	t := rv.Elem().Type()
+
+	r.mu.RLock()
+	name, ok := r.byType[t]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("json: %s is not registered for %s", t, reflect.TypeFor[I]())
+	}
+
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return nil, errors.Errorf("json: %s must encode as an object to carry a discriminator", t)
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	if _, ok := members[r.key]; ok {
+		return nil, errors.Errorf("json: %s already has a member named %q", t, r.key)
+	}
+
+	key, _ := json.Marshal(r.key)
+	value, _ := json.Marshal(name)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(value)
+	if rest := bytes.TrimSpace(data[1:]); rest[0] != '}' {
+		buf.WriteByte(',')
+	}
+	buf.Write(data[1:])
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data into a new value of the concrete type selected by
+// its discriminator. null decodes into a nil I.
+func (r *Registry[I]) Unmarshal(data []byte) (I, error) {
+	var zero I
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return zero, nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return zero, err
+	}
+	raw, ok := members[r.key]
+	if !ok {
+		return zero, errors.Errorf("json: missing discriminator %q for %s", r.key, reflect.TypeFor[I]())
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return zero, errors.Wrapf(err, "json: invalid discriminator %q", r.key)
+	}
+
+	r.mu.RLock()
+	t, ok := r.byName[name]
+	r.mu.RUnlock()
+	if !ok {
+		return zero, errors.Errorf("json: unknown %s %q for %s", r.key, name, reflect.TypeFor[I]())
+	}
+
+	var rv reflect.Value
+	if t.Kind() == reflect.Pointer {
+		rv = reflect.New(t.Elem())
+		if err := json.Unmarshal(data, rv.Interface()); err != nil {
+			return zero, err
+		}
+	} else {
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+			return zero, err
+		}
+		rv = ptr.Elem()
+	}
+	return rv.Interface().(I), nil
+}
+
+// Union holds a value of the interface I and encodes it through the
+// registry returned by Polymorphic[I], use it for interface-typed fields.
+type Union[I any] struct {
+	Value I
+}
+
+func (u Union[I]) MarshalJSON() ([]byte, error) {
+	r, err := lookupRegistry[I]()
+	if err != nil {
+		return nil, err
+	}
+	return r.Marshal(u.Value)
+}
+
+func (u *Union[I]) UnmarshalJSON(data []byte) error {
+	r, err := lookupRegistry[I]()
+	if err != nil {
+		return err
+	}
+	v, err := r.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	u.Value = v
+	return nil
+}
+
+// Slice is a slice of the interface I encoded through the registry returned
+// by Polymorphic[I], it converts to and from []I directly.
+type Slice[I any] []I
+
+func (s Slice[I]) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	r, err := lookupRegistry[I]()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]json.RawMessage, len(s))
+	for i, v := range s {
+		if items[i], err = r.Marshal(v); err != nil {
+			return nil, errors.Wrapf(err, "index %d", i)
+		}
+	}
+	return json.Marshal(items)
+}
+
+func (s *Slice[I]) UnmarshalJSON(data []byte) error {
+	r, err := lookupRegistry[I]()
+	if err != nil {
+		return err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	if items == nil {
+		*s = nil
+		return nil
+	}
+	out := make(Slice[I], len(items))
+	for i, item := range items {
+		if out[i], err = r.Unmarshal(item); err != nil {
+			return errors.Wrapf(err, "index %d", i)
+		}
+	}
+	*s = out
+	return nil
+}
@@ -0,0 +1,92 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var identifiables = func() *Registry[Identifiable] {
+	r := Polymorphic[Identifiable]("type")
+	Register[*A](r, "a")
+	Register[*B](r, "b")
+	return r
+}()
+
+func TestPolymorphic(t *testing.T) {
+	{
+		data, err := identifiables.Marshal(&A{ID: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, `{"type":"a","id":"1"}`, string(data))
+
+		v, err := identifiables.Unmarshal(data)
+		require.NoError(t, err)
+		assert.Equal(t, &A{ID: "1"}, v)
+	}
+	{
+		// 空对象也要能带上 discriminator
+		data, err := identifiables.Marshal(&B{})
+		require.NoError(t, err)
+		assert.Equal(t, `{"type":"b"}`, string(data))
+	}
+	{
+		data, err := identifiables.Marshal(nil)
+		require.NoError(t, err)
+		assert.Equal(t, `null`, string(data))
+
+		v, err := identifiables.Unmarshal(data)
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	}
+	{
+		_, err := identifiables.Unmarshal([]byte(`{"type":"c"}`))
+		assert.ErrorContains(t, err, `unknown type "c"`)
+
+		_, err = identifiables.Unmarshal([]byte(`{"id":"1"}`))
+		assert.ErrorContains(t, err, `missing discriminator "type"`)
+	}
+	{
+		// A 是注册了指针类型 *A ，值类型 A 并未实现 Identifiable
+		assert.Panics(t, func() { Register[A](identifiables, "a2") })
+		assert.Panics(t, func() { Register[*A](identifiables, "a3") })
+		assert.Panics(t, func() { Polymorphic[Identifiable]("kind") })
+	}
+}
+
+func TestUnionAndSlice(t *testing.T) {
+	// 对比 E 和 H ，embed interface 只能序列化成嵌套对象，并且无法反序列化回来
+	type Event struct {
+		Name    string              `json:"name"`
+		Payload Union[Identifiable] `json:"payload"`
+		Items   Slice[Identifiable] `json:"items"`
+	}
+
+	event := Event{
+		Name:    "created",
+		Payload: Union[Identifiable]{Value: &A{ID: "a"}},
+		Items:   Slice[Identifiable]{&B{ID: "b"}, &A{ID: "c"}},
+	}
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "created",
+		"payload": {"type": "a", "id": "a"},
+		"items": [{"type": "b", "id": "b"}, {"type": "a", "id": "c"}]
+	}`, string(data))
+
+	got, err := Unmarshal[Event](data)
+	require.NoError(t, err)
+	assert.Equal(t, event, got)
+
+	// Slice[I] 和 []I 可以直接互相转换
+	items := []Identifiable(got.Items)
+	assert.Equal(t, "b", items[0].GetID())
+
+	{
+		// 未注册的 interface 会报错
+		_, err := json.Marshal(Union[error]{})
+		assert.ErrorContains(t, err, "no polymorphic registry for error")
+	}
+}
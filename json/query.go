@@ -0,0 +1,718 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Path is a compiled JSONPath expression (RFC 9535).
+//
+// Supported are the root $, child segments .name, ['name'] and [*],
+// descendant segments .., indexes [0] and [-1], unions [0,1], slices
+// [start:end:step] and filters [?(@.age > 18 && @.name)] with comparisons,
+// existence tests, !, && and || over relative (@) and absolute ($) paths.
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// CompilePath parses a JSONPath expression.
+func CompilePath(expr string) (*Path, error) {
+	p := &pathParser{src: expr}
+	segments, err := p.parseQuery()
+	if err != nil {
+		return nil, errors.Wrapf(err, "json: invalid path %q", expr)
+	}
+	return &Path{expr: expr, segments: segments}, nil
+}
+
+// MustCompilePath is like CompilePath but panics on error.
+func MustCompilePath(expr string) *Path {
+	p, err := CompilePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Select returns the nodes of the decoded document doc selected by p, in
+// document order. Object members are visited in name order.
+func (p *Path) Select(doc any) []any {
+	return selectSegments(p.segments, doc, doc)
+}
+
+// Query decodes data and returns the values selected by the JSONPath expr.
+// Numbers are returned as json.Number so that no precision is lost.
+func Query(data []byte, expr string) ([]any, error) {
+	p, err := CompilePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	return p.Select(doc), nil
+}
+
+// QueryAs is like Query but decodes every selected value into T.
+func QueryAs[T any](data []byte, expr string) ([]T, error) {
+	values, err := Query(data, expr)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(values))
+	for i, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		t, err := Unmarshal[T](raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "json: result %d", i)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selector interface {
+	apply(node, root any, out []any) []any
+}
+
+func selectSegments(segments []segment, node, root any) []any {
+	nodes := []any{node}
+	for _, seg := range segments {
+		var next []any
+		for _, n := range nodes {
+			targets := []any{n}
+			if seg.descendant {
+				targets = descendants(n, nil)
+			}
+			for _, t := range targets {
+				for _, s := range seg.selectors {
+					next = s.apply(t, root, next)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants returns node and all of its descendants in document order.
+func descendants(node any, out []any) []any {
+	out = append(out, node)
+	switch node := node.(type) {
+	case []any:
+		for _, v := range node {
+			out = descendants(v, out)
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(node) {
+			out = descendants(node[k], out)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type nameSelector string
+
+func (s nameSelector) apply(node, _ any, out []any) []any {
+	if m, ok := node.(map[string]any); ok {
+		if v, ok := m[string(s)]; ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(node, _ any, out []any) []any {
+	switch node := node.(type) {
+	case []any:
+		out = append(out, node...)
+	case map[string]any:
+		for _, k := range sortedKeys(node) {
+			out = append(out, node[k])
+		}
+	}
+	return out
+}
+
+type indexSelector int
+
+func (s indexSelector) apply(node, _ any, out []any) []any {
+	if a, ok := node.([]any); ok {
+		i := int(s)
+		if i < 0 {
+			i += len(a)
+		}
+		if i >= 0 && i < len(a) {
+			out = append(out, a[i])
+		}
+	}
+	return out
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(node, _ any, out []any) []any {
+	a, ok := node.([]any)
+	if !ok || s.step == 0 {
+		return out
+	}
+	n := len(a)
+	normalize := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		return max(lo, min(i, hi))
+	}
+	if s.step > 0 {
+		lower, upper := 0, n
+		if s.start != nil {
+			lower = clamp(normalize(*s.start), 0, n)
+		}
+		if s.end != nil {
+			upper = clamp(normalize(*s.end), 0, n)
+		}
+		for i := lower; i < upper; i += s.step {
+			out = append(out, a[i])
+		}
+		return out
+	}
+	upper, lower := n-1, -1
+	if s.start != nil {
+		upper = clamp(normalize(*s.start), -1, n-1)
+	}
+	if s.end != nil {
+		lower = clamp(normalize(*s.end), -1, n-1)
+	}
+	for i := upper; lower < i; i += s.step {
+		out = append(out, a[i])
+	}
+	return out
+}
+
+type filterSelector struct {
+	expr filterExpr
+}
+
+func (s filterSelector) apply(node, root any, out []any) []any {
+	switch node := node.(type) {
+	case []any:
+		for _, v := range node {
+			if s.expr.test(v, root) {
+				out = append(out, v)
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(node) {
+			if s.expr.test(node[k], root) {
+				out = append(out, node[k])
+			}
+		}
+	}
+	return out
+}
+
+type filterExpr interface {
+	test(current, root any) bool
+}
+
+type orExpr struct{ left, right filterExpr }
+
+func (e orExpr) test(cur, root any) bool { return e.left.test(cur, root) || e.right.test(cur, root) }
+
+type andExpr struct{ left, right filterExpr }
+
+func (e andExpr) test(cur, root any) bool { return e.left.test(cur, root) && e.right.test(cur, root) }
+
+type notExpr struct{ expr filterExpr }
+
+func (e notExpr) test(cur, root any) bool { return !e.expr.test(cur, root) }
+
+type existExpr struct{ path pathOperand }
+
+func (e existExpr) test(cur, root any) bool { return len(e.path.nodes(cur, root)) > 0 }
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e compareExpr) test(cur, root any) bool {
+	l, lok := e.left.value(cur, root)
+	r, rok := e.right.value(cur, root)
+	switch e.op {
+	case "==":
+		return lok == rok && (!lok || equalJSON(l, r))
+	case "!=":
+		return !(lok == rok && (!lok || equalJSON(l, r)))
+	}
+	if !lok || !rok {
+		return false
+	}
+	c, ok := compareValues(l, r)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b any) (int, bool) {
+	if x, ok := toBigFloat(a); ok {
+		y, ok := toBigFloat(b)
+		if !ok {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	}
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+type operand interface {
+	// value returns the single value of the operand, false means Nothing.
+	value(current, root any) (any, bool)
+}
+
+type literalOperand struct{ v any }
+
+func (o literalOperand) value(_, _ any) (any, bool) { return o.v, true }
+
+type pathOperand struct {
+	absolute bool
+	segments []segment
+}
+
+func (o pathOperand) nodes(cur, root any) []any {
+	start := cur
+	if o.absolute {
+		start = root
+	}
+	return selectSegments(o.segments, start, root)
+}
+
+func (o pathOperand) value(cur, root any) (any, bool) {
+	nodes := o.nodes(cur, root)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+type pathParser struct {
+	src string
+	pos int
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return errors.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) parseQuery() ([]segment, error) {
+	if !p.consume("$") {
+		return nil, p.errorf("expected $")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return segments, nil
+}
+
+func (p *pathParser) parseSegments() ([]segment, error) {
+	var segments []segment
+	for {
+		switch {
+		case p.consume(".."):
+			seg, err := p.parseSegmentBody(true)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		case p.consume("."):
+			seg, err := p.parseSegmentBody(false)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		case p.peek() == '[':
+			seg, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		default:
+			return segments, nil
+		}
+	}
+}
+
+// parseSegmentBody parses what follows . or .., a name, * or a bracket
+// (the latter only after ..).
+func (p *pathParser) parseSegmentBody(descendant bool) (segment, error) {
+	if p.consume("*") {
+		return segment{descendant: descendant, selectors: []selector{wildcardSelector{}}}, nil
+	}
+	if descendant && p.peek() == '[' {
+		seg, err := p.parseBracket()
+		seg.descendant = true
+		return seg, err
+	}
+	name := p.parseName()
+	if name == "" {
+		return segment{}, p.errorf("expected member name")
+	}
+	return segment{descendant: descendant, selectors: []selector{nameSelector(name)}}, nil
+}
+
+func (p *pathParser) parseName() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r == '_' || unicode.IsLetter(r) || r >= 0x80 || (p.pos > start && unicode.IsDigit(r)) {
+			p.pos += size
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *pathParser) parseBracket() (segment, error) {
+	p.consume("[")
+	var seg segment
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return segment{}, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipSpace()
+		if p.consume(",") {
+			continue
+		}
+		if p.consume("]") {
+			return seg, nil
+		}
+		return segment{}, p.errorf("expected , or ]")
+	}
+}
+
+func (p *pathParser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector(s), nil
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr: expr}, nil
+	case c == ':' || c == '-' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+	default:
+		return nil, p.errorf("unexpected selector")
+	}
+}
+
+func (p *pathParser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+	n := 0
+	for {
+		p.skipSpace()
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			i, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			parts[n] = &i
+		}
+		p.skipSpace()
+		if n < 2 && p.consume(":") {
+			n++
+			continue
+		}
+		break
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, p.errorf("expected index")
+		}
+		return indexSelector(*parts[0]), nil
+	}
+	s := sliceSelector{start: parts[0], end: parts[1], step: 1}
+	if parts[2] != nil {
+		s.step = *parts[2]
+	}
+	return s, nil
+}
+
+func (p *pathParser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	i, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", p.src[start:p.pos])
+	}
+	return i, nil
+}
+
+// parseString parses a single or double quoted string literal with JSON
+// style escapes.
+func (p *pathParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			p.pos++
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			esc := p.src[p.pos]
+			switch esc {
+			case 'u':
+				if p.pos+5 > len(p.src) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				s, err := strconv.Unquote(`"\u` + p.src[p.pos+1:p.pos+5] + `"`)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape")
+				}
+				sb.WriteString(s)
+				p.pos += 5
+				continue
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(esc)
+			}
+			p.pos++
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			p.skipSpace()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	path, ok := left.(pathOperand)
+	if !ok {
+		return nil, p.errorf("literal must be compared")
+	}
+	return existExpr{path}, nil
+}
+
+func (p *pathParser) parseOperand() (operand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{absolute: c == '$', segments: segments}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand{s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for !p.eof() && strings.IndexByte("+-.eE0123456789", p.peek()) >= 0 {
+			p.pos++
+		}
+		n := json.Number(p.src[start:p.pos])
+		if _, err := n.Float64(); err != nil {
+			return nil, p.errorf("invalid number %q", n)
+		}
+		return literalOperand{n}, nil
+	case p.consume("true"):
+		return literalOperand{true}, nil
+	case p.consume("false"):
+		return literalOperand{false}, nil
+	case p.consume("null"):
+		return literalOperand{nil}, nil
+	default:
+		return nil, p.errorf("expected operand")
+	}
+}
@@ -0,0 +1,86 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const queryDoc = `{
+	"store": "s1",
+	"users": [
+		{"id": 9007199254740993, "name": "alice", "age": 30, "tags": ["a", "b"]},
+		{"id": 2, "name": "bob", "age": 17},
+		{"id": 3, "name": "carol", "age": 42, "admin": true}
+	],
+	"limits": {"age": 18}
+}`
+
+func TestQuery(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{`$.store`, `["s1"]`},
+		{`$.users[0].name`, `["alice"]`},
+		{`$['users'][-1]["name"]`, `["carol"]`},
+		{`$.users[*].age`, `[30, 17, 42]`},
+		{`$.users[0,2].name`, `["alice", "carol"]`},
+		{`$.users[1:].name`, `["bob", "carol"]`},
+		{`$.users[::-1].name`, `["carol", "bob", "alice"]`},
+		{`$.users[?(@.age>18)].name`, `["alice", "carol"]`},
+		{`$.users[?@.age >= $.limits.age && !@.admin].name`, `["alice"]`},
+		{`$.users[?(@.admin || @.name == 'bob')].id`, `[2, 3]`},
+		{`$.users[?@.tags].name`, `["alice"]`},
+		{`$.users[?@.tags[1] == "b"].name`, `["alice"]`},
+		{`$..age`, `[18, 30, 17, 42]`},
+		{`$..tags[0]`, `["a"]`},
+		{`$.limits.*`, `[18]`},
+		{`$.missing`, `[]`},
+	}
+	for _, c := range cases {
+		result, err := Query([]byte(queryDoc), c.expr)
+		require.NoError(t, err, c.expr)
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		if c.expected == `[]` {
+			assert.Empty(t, result, c.expr)
+			continue
+		}
+		assert.JSONEq(t, c.expected, string(data), c.expr)
+	}
+
+	for _, expr := range []string{`users`, `$.`, `$[`, `$[?@.a ==]`, `$[?(@.a]`, `$['a`, `$.a b`} {
+		_, err := Query([]byte(queryDoc), expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestQueryAs(t *testing.T) {
+	// 和 Unmarshal[any] 不同，大整数不会因为 float64 丢失精度
+	ids, err := QueryAs[int64]([]byte(queryDoc), `$.users[*].id`)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{9007199254740993, 2, 3}, ids)
+
+	type User struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	users, err := QueryAs[User]([]byte(queryDoc), `$.users[?@.age < 18]`)
+	require.NoError(t, err)
+	assert.Equal(t, []User{{Name: "bob", Age: 17}}, users)
+
+	_, err = QueryAs[int]([]byte(queryDoc), `$.users[*].name`)
+	assert.ErrorContains(t, err, "json: result 0")
+}
+
+func TestPathSelect(t *testing.T) {
+	// 也可以直接作用在 Unmarshal[any] 得到的树上，数字是 float64 也能正常比较
+	doc, err := Unmarshal[any]([]byte(queryDoc))
+	require.NoError(t, err)
+	p := MustCompilePath(`$.users[?@.age > 18 && @.id != 3].name`)
+	assert.Equal(t, []any{"alice"}, p.Select(doc))
+	assert.Equal(t, `$.users[?@.age > 18 && @.id != 3].name`, p.String())
+}
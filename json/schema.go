@@ -0,0 +1,251 @@
+package json
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+// SchemaDraft is the JSON Schema dialect emitted by Schema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Schema returns the JSON Schema (draft 2020-12) describing how
+// encoding/json encodes values of type T.
+//
+// Fields follow json tags and the embedded-struct promotion rules of
+// encoding/json, fields without omitempty are required, pointers, slices
+// and maps also accept null, time.Time is a date-time string and named struct types are
+// placed in $defs so that recursive types are supported. Types with a custom
+// MarshalJSON are unconstrained.
+func Schema[T any]() ([]byte, error) {
+	return SchemaOf(reflect.TypeFor[T]())
+}
+
+// SchemaOf is like Schema but takes a reflect.Type.
+func SchemaOf(t reflect.Type) ([]byte, error) {
+	if t == nil {
+		return nil, errors.New("json: cannot generate schema for nil type")
+	}
+	g := &schemaGen{
+		root:  t,
+		defs:  map[string]any{},
+		names: map[reflect.Type]string{},
+		taken: map[string]reflect.Type{},
+	}
+	s, err := g.schema(t)
+	if err != nil {
+		return nil, err
+	}
+	root := map[string]any{"$schema": SchemaDraft}
+	for k, v := range s {
+		root[k] = v
+	}
+	if len(g.defs) > 0 {
+		root["$defs"] = g.defs
+	}
+	return json.Marshal(root)
+}
+
+type schemaGen struct {
+	root  reflect.Type
+	defs  map[string]any
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+func (g *schemaGen) schema(t reflect.Type) (map[string]any, error) {
+	if t.Kind() == reflect.Pointer {
+		s, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(s), nil
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case t == rawMessageType:
+		return map[string]any{}, nil
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		return map[string]any{}, nil
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		// nil slices and maps are encoded as null
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice &&
+			!reflect.PointerTo(t.Elem()).Implements(marshalerType) &&
+			!reflect.PointerTo(t.Elem()).Implements(textMarshalerType) {
+			return nullable(map[string]any{"type": "string", "contentEncoding": "base64"}), nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			s["minItems"] = t.Len()
+			s["maxItems"] = t.Len()
+			return s, nil
+		}
+		return nullable(s), nil
+	case reflect.Map:
+		s := map[string]any{"type": "object"}
+		switch t.Key().Kind() {
+		case reflect.String:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			s["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		default:
+			if !t.Key().Implements(textMarshalerType) {
+				return nil, &json.UnsupportedTypeError{Type: t}
+			}
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s["additionalProperties"] = values
+		return nullable(s), nil
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		return nil, &json.UnsupportedTypeError{Type: t}
+	}
+}
+
+// structRef returns a $ref to the definition of a named struct, generating it
+// on first use, anonymous structs are inlined.
+func (g *schemaGen) structRef(t reflect.Type) (map[string]any, error) {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+	if t == g.root {
+		if _, ok := g.names[t]; ok {
+			return map[string]any{"$ref": "#"}, nil
+		}
+		g.names[t] = "#"
+		return g.structSchema(t)
+	}
+	if name, ok := g.names[t]; ok {
+		return map[string]any{"$ref": "#/$defs/" + escapePointerToken(name)}, nil
+	}
+	name := t.Name()
+	if prev, ok := g.taken[name]; ok && prev != t {
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+	}
+	// types of the same name in the same package, such as function local
+	// ones, are told apart by a number
+	for base, i := name, 2; ; i++ {
+		if prev, ok := g.taken[name]; !ok || prev == t {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	g.taken[name] = t
+	g.names[t] = name
+	s, err := g.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	g.defs[name] = s
+	return map[string]any{"$ref": "#/$defs/" + escapePointerToken(name)}, nil
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) (map[string]any, error) {
+	properties := map[string]any{}
+	required := []string{}
+	for _, f := range structfield.Resolve(reflectType{t}).Fields {
+		ft := f.Type.(reflectType).Type
+		s, err := g.schema(ft)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", strings.Join(f.Path, "."))
+		}
+		if f.Quoted && isQuotable(ft) {
+			s = map[string]any{"type": "string"}
+			if ft.Kind() == reflect.Pointer {
+				s = nullable(s)
+			}
+		}
+		properties[f.Name] = s
+		if !f.OmitEmpty && !throughPointer(t, f.Index) {
+			required = append(required, f.Name)
+		}
+	}
+	s := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s, nil
+}
+
+// throughPointer reports whether a promoted field is reached through an
+// embedded pointer, in which case it is absent when that pointer is nil.
+func throughPointer(t reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Pointer {
+			return true
+		}
+		t = ft
+	}
+	return false
+}
+
+// isQuotable reports whether the ",string" option applies to t, like
+// encoding/json through an unnamed pointer only.
+func isQuotable(t reflect.Type) bool {
+	switch unnamedElem(t).Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+	return false
+}
+
+func nullable(s map[string]any) map[string]any {
+	if typ, ok := s["type"].(string); ok {
+		out := map[string]any{}
+		for k, v := range s {
+			out[k] = v
+		}
+		out["type"] = []string{typ, "null"}
+		return out
+	}
+	if len(s) == 0 {
+		return s
+	}
+	return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+}
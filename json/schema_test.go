@@ -0,0 +1,129 @@
+package json
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	type Address struct {
+		Line string `json:"address_line"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	type Node struct {
+		Value    int     `json:"value"`
+		Children []*Node `json:"children,omitempty"`
+	}
+	type User struct {
+		G                        // id 和 name 都被提升
+		Age       uint8          `json:"age,string"`
+		Email     *string        `json:"email"`
+		Tags      []string       `json:"tags,omitempty"`
+		Avatar    []byte         `json:"avatar,omitempty"`
+		Addresses []Address      `json:"addresses"`
+		Labels    map[string]int `json:"labels,omitempty"`
+		CreatedAt time.Time      `json:"created_at"`
+		DeletedAt *time.Time     `json:"deleted_at"`
+		Tree      Node           `json:"tree"`
+		Extra     any            `json:"extra,omitempty"`
+		Ignored   string         `json:"-"`
+		internal  string
+	}
+
+	data, err := Schema[User]()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"name": {"type": "string"},
+			"age": {"type": "string"},
+			"email": {"type": ["string", "null"]},
+			"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+			"avatar": {"type": ["string", "null"], "contentEncoding": "base64"},
+			"addresses": {"type": ["array", "null"], "items": {"$ref": "#/$defs/Address"}},
+			"labels": {"type": ["object", "null"], "additionalProperties": {"type": "integer"}},
+			"created_at": {"type": "string", "format": "date-time"},
+			"deleted_at": {"type": ["string", "null"], "format": "date-time"},
+			"tree": {"$ref": "#/$defs/Node"},
+			"extra": {}
+		},
+		"required": ["name", "age", "email", "addresses", "created_at", "deleted_at", "tree"],
+		"$defs": {
+			"Address": {
+				"type": "object",
+				"properties": {
+					"address_line": {"type": "string"},
+					"zip": {"type": "string"}
+				},
+				"required": ["address_line"]
+			},
+			"Node": {
+				"type": "object",
+				"properties": {
+					"value": {"type": "integer"},
+					"children": {"type": ["array", "null"], "items": {"anyOf": [{"$ref": "#/$defs/Node"}, {"type": "null"}]}}
+				},
+				"required": ["value"]
+			}
+		}
+	}`, string(data))
+
+	{
+		// 通过 embed 指针提升的字段，指针为 nil 时不会输出，所以不是 required
+		type Outer struct {
+			*F
+			Count int `json:"count"`
+		}
+		data, err := Schema[Outer]()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type": "object",
+			"properties": {"name": {"type": "string"}, "count": {"type": "integer"}},
+			"required": ["count"]
+		}`, string(data))
+	}
+	{
+		// 根类型自引用
+		data, err := Schema[Node]()
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `{"anyOf":[{"$ref":"#"},{"type":"null"}]}`)
+	}
+	{
+		// 同一个包中同名的函数内类型不会相互覆盖
+		type First = Address
+		type Address struct {
+			Second string `json:"second"`
+		}
+		type Second = Address
+		var data []byte
+		var err error
+		{
+			type Address struct {
+				Third string `json:"third"`
+			}
+			type All struct {
+				A First   `json:"a"`
+				B Second  `json:"b"`
+				C Address `json:"c"`
+			}
+			data, err = Schema[All]()
+		}
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"a":{"$ref":"#/$defs/Address"}`)
+		assert.Contains(t, string(data), `"b":{"$ref":"#/$defs/github.com.molon.tests.json.Address"}`)
+		assert.Contains(t, string(data), `"c":{"$ref":"#/$defs/github.com.molon.tests.json.Address2"}`)
+		assert.Contains(t, string(data), `"third":{"type":"string"}`)
+	}
+	{
+		_, err := Schema[struct {
+			C chan int `json:"c"`
+		}]()
+		assert.ErrorContains(t, err, "field C: json: unsupported type: chan int")
+	}
+}
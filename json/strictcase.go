@@ -0,0 +1,145 @@
+package json
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/molon/tests/json/internal/structfield"
+)
+
+var (
+	unmarshalerType     = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// CaseMismatchError is returned by UnmarshalStrict when an object key only
+// matches a struct field case-insensitively, which encoding/json accepts.
+type CaseMismatchError struct {
+	// Path is the JSON Pointer of the offending member.
+	Path  string
+	Key   string
+	Field string
+	Type  reflect.Type
+}
+
+func (e *CaseMismatchError) Error() string {
+	return "json: key " + strconv.Quote(e.Key) + " at " + e.Path + " does not match field " +
+		strconv.Quote(e.Field) + " of " + e.Type.String() + " case-sensitively"
+}
+
+// UnmarshalStrict is like Unmarshal but matches object keys against struct
+// fields case-sensitively. A key that would only match a field by ignoring
+// case, like {"a":1} for a field A, fails with a *CaseMismatchError instead
+// of populating the field. Unknown keys are still ignored.
+func UnmarshalStrict[T any](data []byte) (T, error) {
+	var zero T
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return zero, err
+	}
+	if err := checkCase(reflect.TypeFor[T](), doc, ""); err != nil {
+		return zero, err
+	}
+	return Unmarshal[T](data)
+}
+
+func checkCase(t reflect.Type, v any, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+	switch node := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Map:
+			for _, k := range sortedKeys(node) {
+				if err := checkCase(t.Elem(), node[k], path+"/"+escapePointerToken(k)); err != nil {
+					return err
+				}
+			}
+		case reflect.Struct:
+			resolved := structfield.Resolve(reflectType{t}).Fields
+			fields := make(map[string]structfield.Resolved, len(resolved))
+			for _, f := range resolved {
+				fields[f.Name] = f
+			}
+			for _, k := range sortedKeys(node) {
+				child := path + "/" + escapePointerToken(k)
+				f, ok := fields[k]
+				if !ok {
+					for _, f := range resolved {
+						if strings.EqualFold(f.Name, k) {
+							return &CaseMismatchError{Path: child, Key: k, Field: f.Name, Type: t}
+						}
+					}
+					continue
+				}
+				if err := checkCase(f.Type.(reflectType).Type, node[k], child); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, e := range node {
+				if err := checkCase(t.Elem(), e, path+"/"+strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CaseCollision is a set of keys of one object that differ only by case,
+// encoding/json may assign any of them to the same struct field.
+type CaseCollision struct {
+	// Path is the JSON Pointer of the object.
+	Path string
+	Keys []string
+}
+
+// CaseCollisions reports every object in data that has keys colliding only
+// by case, e.g. {"id":1,"ID":2}.
+func CaseCollisions(data []byte) ([]CaseCollision, error) {
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	var collisions []CaseCollision
+	var walk func(v any, path string)
+	walk = func(v any, path string) {
+		switch node := v.(type) {
+		case map[string]any:
+			keys := sortedKeys(node)
+			groups := map[string][]string{}
+			var order []string
+			for _, k := range keys {
+				folded := strings.ToLower(k)
+				if _, ok := groups[folded]; !ok {
+					order = append(order, folded)
+				}
+				groups[folded] = append(groups[folded], k)
+			}
+			for _, folded := range order {
+				if len(groups[folded]) > 1 {
+					collisions = append(collisions, CaseCollision{Path: path, Keys: groups[folded]})
+				}
+			}
+			for _, k := range keys {
+				walk(node[k], path+"/"+escapePointerToken(k))
+			}
+		case []any:
+			for i, e := range node {
+				walk(e, path+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+	walk(doc, "")
+	return collisions, nil
+}
@@ -0,0 +1,67 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalStrict(t *testing.T) {
+	type Foo struct {
+		A int
+		B int
+	}
+	{
+		// 对比 TestUnmarshal ，{"a":1} 不会再被赋值给 A
+		_, err := UnmarshalStrict[Foo]([]byte(`{"a":1}`))
+		var mismatch *CaseMismatchError
+		require.ErrorAs(t, err, &mismatch)
+		assert.Equal(t, "/a", mismatch.Path)
+		assert.Equal(t, "A", mismatch.Field)
+		assert.EqualError(t, err, `json: key "a" at /a does not match field "A" of json.Foo case-sensitively`)
+
+		v, err := UnmarshalStrict[*Foo]([]byte(`{"A":1,"c":2}`))
+		require.NoError(t, err)
+		assert.Equal(t, &Foo{A: 1}, v)
+	}
+	{
+		type Item struct {
+			ID string `json:"id"`
+		}
+		type Doc struct {
+			Items  []*Item             `json:"items"`
+			ByName map[string]Item     `json:"by_name"`
+			Extra  map[string]any      `json:"extra"`
+			Raw    Union[Identifiable] `json:"raw,omitempty"`
+		}
+		_, err := UnmarshalStrict[Doc]([]byte(`{"items":[{"id":"1"},{"Id":"2"}]}`))
+		assert.ErrorContains(t, err, `key "Id" at /items/1/Id`)
+
+		_, err = UnmarshalStrict[Doc]([]byte(`{"by_name":{"x":{"ID":"1"}}}`))
+		assert.ErrorContains(t, err, `key "ID" at /by_name/x/ID`)
+
+		// any 和自定义 UnmarshalJSON 的类型不做检查
+		v, err := UnmarshalStrict[Doc]([]byte(`{"extra":{"ID":1},"raw":{"type":"a","ID":"x"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, &A{ID: "x"}, v.Raw.Value)
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	collisions, err := CaseCollisions([]byte(`{"id":1,"ID":2,"users":[{"name":"a","Name":"b","NAME":"c","x":1}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []CaseCollision{
+		{Path: "", Keys: []string{"ID", "id"}},
+		{Path: "/users/0", Keys: []string{"NAME", "Name", "name"}},
+	}, collisions)
+
+	// encoding/json 会静默选择其中一个，最后出现的那个生效
+	v, err := Unmarshal[C]([]byte(`{"id":"1","ID":"2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2", v.ID)
+
+	collisions, err = CaseCollisions([]byte(`{"id":1}`))
+	require.NoError(t, err)
+	assert.Empty(t, collisions)
+}
@@ -0,0 +1,33 @@
+package a
+
+type A struct {
+	ID string `json:"id,omitempty"`
+}
+
+type B struct {
+	ID string `json:"id,omitempty"`
+}
+
+type C struct {
+	A
+}
+
+type D struct { // want `D: json field "id" is dropped, ambiguous between A.ID, B.ID`
+	A
+	B
+}
+
+type Identifiable interface {
+	GetID() string
+}
+
+type E struct { // want `E: embedded interface Identifiable is not promoted, encoded as nested object "Identifiable"`
+	A
+	Identifiable
+}
+
+type Generic[T any] struct { // want `Generic: json field "id" is dropped, ambiguous between A.ID, B.ID`
+	A
+	B
+	Value T `json:"value"`
+}
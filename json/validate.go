@@ -0,0 +1,482 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationError is a violation of a JSON Schema keyword.
+type ValidationError struct {
+	// Path is the JSON Pointer (RFC 6901) of the offending value in the
+	// instance, "" is the document itself.
+	Path string
+	// Keyword is the schema keyword that failed, e.g. "required".
+	Keyword string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// ValidationErrors is returned by UnmarshalValidated when the payload does
+// not match the schema.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return "json: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validate validates data against the JSON Schema document schema.
+//
+// It supports the core keywords type, enum, const, required, properties,
+// additionalProperties, propertyNames, items, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, minLength, maxLength, minItems,
+// maxItems, pattern, allOf, anyOf, oneOf, not and $ref to locations within
+// the same document. A nil result means data is valid.
+func Validate(schema, data []byte) []ValidationError {
+	s, err := decodeNumbers(schema)
+	if err != nil {
+		return []ValidationError{{Message: "invalid schema: " + err.Error()}}
+	}
+	v, err := decodeNumbers(data)
+	if err != nil {
+		return []ValidationError{{Message: "invalid JSON: " + err.Error()}}
+	}
+	vd := &validator{root: s}
+	vd.validate(s, v, "")
+	return vd.errs
+}
+
+var schemaCache sync.Map // reflect.Type -> []byte
+
+// UnmarshalValidated validates data against the schema derived from T by
+// Schema and decodes it only if it is valid, violations are returned as
+// ValidationErrors.
+func UnmarshalValidated[T any](data []byte) (T, error) {
+	var zero T
+	t := reflect.TypeFor[T]()
+	schema, ok := schemaCache.Load(t)
+	if !ok {
+		s, err := SchemaOf(t)
+		if err != nil {
+			return zero, err
+		}
+		schema, _ = schemaCache.LoadOrStore(t, s)
+	}
+	if errs := Validate(schema.([]byte), data); len(errs) > 0 {
+		return zero, ValidationErrors(errs)
+	}
+	return Unmarshal[T](data)
+}
+
+func decodeNumbers(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+type validator struct {
+	root any
+	errs []ValidationError
+}
+
+func (vd *validator) fail(path, keyword, format string, args ...any) {
+	vd.errs = append(vd.errs, ValidationError{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether v matches schema without recording errors.
+func (vd *validator) valid(schema, v any, path string) bool {
+	sub := &validator{root: vd.root}
+	sub.validate(schema, v, path)
+	return len(sub.errs) == 0
+}
+
+func (vd *validator) validate(schema, v any, path string) {
+	var s map[string]any
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			vd.fail(path, "false", "no value is allowed")
+		}
+		return
+	case map[string]any:
+		s = schema
+	default:
+		vd.fail(path, "", "invalid schema %v", schema)
+		return
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := resolveRef(vd.root, ref)
+		if err != nil {
+			vd.fail(path, "$ref", "%v", err)
+		} else {
+			vd.validate(target, v, path)
+		}
+	}
+
+	if typ, ok := s["type"]; ok {
+		var types []string
+		switch typ := typ.(type) {
+		case string:
+			types = []string{typ}
+		case []any:
+			for _, t := range typ {
+				if t, ok := t.(string); ok {
+					types = append(types, t)
+				}
+			}
+		}
+		matched := false
+		for _, t := range types {
+			if isType(v, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			vd.fail(path, "type", "expected %s, got %s", strings.Join(types, " or "), typeOf(v))
+			return
+		}
+	}
+
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if equalJSON(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vd.fail(path, "enum", "value must be one of %s", marshalString(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !equalJSON(c, v) {
+		vd.fail(path, "const", "value must be %s", marshalString(c))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		vd.validateObject(s, v, path)
+	case []any:
+		vd.validateArray(s, v, path)
+	case string:
+		vd.validateString(s, v, path)
+	case json.Number:
+		vd.validateNumber(s, v, path)
+	}
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			vd.validate(sub, v, path)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if vd.valid(sub, v, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			vd.fail(path, "anyOf", "value does not match any schema")
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if vd.valid(sub, v, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			vd.fail(path, "oneOf", "value must match exactly one schema, matched %d", matched)
+		}
+	}
+	if not, ok := s["not"]; ok && vd.valid(not, v, path) {
+		vd.fail(path, "not", "value must not match the schema")
+	}
+}
+
+func (vd *validator) validateObject(s map[string]any, v map[string]any, path string) {
+	if required, ok := s["required"].([]any); ok {
+		for _, name := range required {
+			name, _ := name.(string)
+			if _, ok := v[name]; !ok {
+				vd.fail(path, "required", "missing required property %q", name)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	properties, _ := s["properties"].(map[string]any)
+	additional, hasAdditional := s["additionalProperties"]
+	names, hasNames := s["propertyNames"]
+	for _, k := range keys {
+		child := path + "/" + escapePointerToken(k)
+		if hasNames && !vd.valid(names, k, child) {
+			vd.fail(child, "propertyNames", "invalid property name %q", k)
+		}
+		if sub, ok := properties[k]; ok {
+			vd.validate(sub, v[k], child)
+		} else if hasAdditional {
+			if b, ok := additional.(bool); ok && !b {
+				vd.fail(child, "additionalProperties", "property %q is not allowed", k)
+			} else {
+				vd.validate(additional, v[k], child)
+			}
+		}
+	}
+}
+
+func (vd *validator) validateArray(s map[string]any, v []any, path string) {
+	if n, ok := intKeyword(s, "minItems"); ok && len(v) < n {
+		vd.fail(path, "minItems", "array must have at least %d items", n)
+	}
+	if n, ok := intKeyword(s, "maxItems"); ok && len(v) > n {
+		vd.fail(path, "maxItems", "array must have at most %d items", n)
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range v {
+			vd.validate(items, item, path+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+func (vd *validator) validateString(s map[string]any, v string, path string) {
+	length := utf8.RuneCountInString(v)
+	if n, ok := intKeyword(s, "minLength"); ok && length < n {
+		vd.fail(path, "minLength", "string must be at least %d characters", n)
+	}
+	if n, ok := intKeyword(s, "maxLength"); ok && length > n {
+		vd.fail(path, "maxLength", "string must be at most %d characters", n)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			vd.fail(path, "pattern", "invalid pattern %q: %v", pattern, err)
+		} else if !re.MatchString(v) {
+			vd.fail(path, "pattern", "string must match pattern %q", pattern)
+		}
+	}
+}
+
+func (vd *validator) validateNumber(s map[string]any, v json.Number, path string) {
+	x, ok := new(big.Float).SetString(v.String())
+	if !ok {
+		vd.fail(path, "type", "invalid number %s", v)
+		return
+	}
+	bound := func(keyword string) (*big.Float, bool) {
+		n, ok := s[keyword].(json.Number)
+		if !ok {
+			return nil, false
+		}
+		return new(big.Float).SetString(n.String())
+	}
+	if b, ok := bound("minimum"); ok && x.Cmp(b) < 0 {
+		vd.fail(path, "minimum", "value must be >= %s", s["minimum"])
+	}
+	if b, ok := bound("maximum"); ok && x.Cmp(b) > 0 {
+		vd.fail(path, "maximum", "value must be <= %s", s["maximum"])
+	}
+	if b, ok := bound("exclusiveMinimum"); ok && x.Cmp(b) <= 0 {
+		vd.fail(path, "exclusiveMinimum", "value must be > %s", s["exclusiveMinimum"])
+	}
+	if b, ok := bound("exclusiveMaximum"); ok && x.Cmp(b) >= 0 {
+		vd.fail(path, "exclusiveMaximum", "value must be < %s", s["exclusiveMaximum"])
+	}
+}
+
+func intKeyword(s map[string]any, keyword string) (int, bool) {
+	n, ok := s[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return 0, false
+	}
+	return int(i), true
+}
+
+var patterns sync.Map // string -> *regexp.Regexp
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func isType(v any, typ string) bool {
+	switch typ {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, ok := new(big.Float).SetString(n.String())
+		return ok && f.IsInt()
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if isType(v, "integer") {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equalJSON compares two decoded values, numbers compare by value whatever
+// their Go representation.
+func equalJSON(a, b any) bool {
+	if x, ok := toBigFloat(a); ok {
+		y, ok := toBigFloat(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalJSON(av, bv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// toBigFloat converts a decoded number to a big.Float.
+func toBigFloat(v any) (*big.Float, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return new(big.Float).SetString(v.String())
+	case float64:
+		return big.NewFloat(v), !math.IsNaN(v)
+	case float32:
+		return big.NewFloat(float64(v)), !math.IsNaN(float64(v))
+	case int:
+		return new(big.Float).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Float).SetInt64(v), true
+	case uint64:
+		return new(big.Float).SetUint64(v), true
+	case *big.Int:
+		return new(big.Float).SetInt(v), v != nil
+	case *big.Float:
+		return v, v != nil
+	}
+	return nil, false
+}
+
+func marshalString(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// resolveRef resolves a $ref of the form "#" or "#/json/pointer" against
+// the root schema.
+func resolveRef(root any, ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("unsupported $ref %q, only references within the document are supported", ref)
+	}
+	p, err := ParsePointer(ref[1:])
+	if err != nil {
+		return nil, errors.Errorf("unsupported $ref %q", ref)
+	}
+	v, err := p.Get(root)
+	if err != nil {
+		return nil, errors.Errorf("unresolvable $ref %q", ref)
+	}
+	return v, nil
+}
@@ -0,0 +1,116 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	schema := []byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 5},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"role": {"enum": ["admin", "user"]},
+			"email": {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"home": {"$ref": "#/$defs/Address"},
+			"contact": {"oneOf": [{"type": "string"}, {"$ref": "#/$defs/Address"}]},
+			"score": {"anyOf": [{"type": "integer"}, {"type": "string"}]},
+			"level": {"allOf": [{"minimum": 1}, {"maximum": 3}]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"$defs": {
+			"Address": {
+				"type": "object",
+				"properties": {"a/b": {"type": "string"}},
+				"required": ["a/b"]
+			}
+		}
+	}`)
+
+	assert.Nil(t, Validate(schema, []byte(`{
+		"name": "bob", "age": 30, "role": "admin", "email": null,
+		"tags": ["x"], "home": {"a/b": "x"}, "contact": "c", "score": "s", "level": 2
+	}`)))
+
+	errs := Validate(schema, []byte(`{
+		"name": "", "age": 1.5, "role": "root", "email": "bad",
+		"tags": ["x", 1, "z"], "home": {"a/b": 1}, "contact": 1, "score": true, "level": 4,
+		"extra": 1
+	}`))
+	assert.Equal(t, []ValidationError{
+		{Path: "/age", Keyword: "type", Message: "expected integer, got number"},
+		{Path: "/contact", Keyword: "oneOf", Message: "value must match exactly one schema, matched 0"},
+		{Path: "/email", Keyword: "pattern", Message: `string must match pattern "^[^@]+@[^@]+$"`},
+		{Path: "/extra", Keyword: "additionalProperties", Message: `property "extra" is not allowed`},
+		{Path: "/home/a~1b", Keyword: "type", Message: "expected string, got integer"},
+		{Path: "/level", Keyword: "maximum", Message: "value must be <= 3"},
+		{Path: "/name", Keyword: "minLength", Message: "string must be at least 1 characters"},
+		{Path: "/role", Keyword: "enum", Message: `value must be one of ["admin","user"]`},
+		{Path: "/score", Keyword: "anyOf", Message: "value does not match any schema"},
+		{Path: "/tags", Keyword: "maxItems", Message: "array must have at most 2 items"},
+		{Path: "/tags/1", Keyword: "type", Message: "expected string, got integer"},
+	}, errs)
+
+	errs = Validate(schema, []byte(`{"age": 150}`))
+	assert.Equal(t, []ValidationError{
+		{Path: "", Keyword: "required", Message: `missing required property "name"`},
+		{Path: "/age", Keyword: "exclusiveMaximum", Message: "value must be < 150"},
+	}, errs)
+	assert.Equal(t, `/: missing required property "name"`, errs[0].Error())
+
+	// 大整数不会因为 float64 丢失精度
+	assert.NotNil(t, Validate([]byte(`{"maximum": 9007199254740992}`), []byte(`9007199254740993`)))
+
+	assert.Equal(t, "invalid JSON: unexpected EOF", Validate(schema, []byte(`{`))[0].Message)
+	assert.Equal(t, []ValidationError{{Keyword: "false", Message: "no value is allowed"}}, Validate([]byte(`false`), []byte(`1`)))
+}
+
+func TestUnmarshalValidated(t *testing.T) {
+	type Person struct {
+		Name     string     `json:"name"`
+		Age      int        `json:"age"`
+		Nickname *string    `json:"nickname,omitempty"`
+		Birthday *time.Time `json:"birthday"`
+	}
+
+	v, err := UnmarshalValidated[Person]([]byte(`{"name": "Alice", "age": 30, "birthday": null}`))
+	require.NoError(t, err)
+	assert.Equal(t, Person{Name: "Alice", Age: 30}, v)
+
+	// encoding/json 会把 30.5 报错为 UnmarshalTypeError ，缺失字段则会静默忽略
+	_, err = UnmarshalValidated[Person]([]byte(`{"name": "Alice", "age": 30.5}`))
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, ValidationErrors{
+		{Path: "", Keyword: "required", Message: `missing required property "birthday"`},
+		{Path: "/age", Keyword: "type", Message: "expected integer, got number"},
+	}, errs)
+	assert.EqualError(t, err, `json: validation failed: /: missing required property "birthday"; /age: expected integer, got number`)
+}
+
+func TestUnmarshalValidatedZeroValue(t *testing.T) {
+	// nil 的 slice 和 map 会被编码为 null ，零值编码后应当能通过校验
+	type Doc struct {
+		Tags   []string       `json:"tags"`
+		Labels map[string]int `json:"labels"`
+		Data   []byte         `json:"data"`
+		Point  [2]int         `json:"point"`
+	}
+	data, err := json.Marshal(Doc{})
+	require.NoError(t, err)
+	v, err := UnmarshalValidated[Doc](data)
+	require.NoError(t, err)
+	assert.Equal(t, Doc{}, v)
+
+	// 数组总是被编码为 JSON 数组
+	_, err = UnmarshalValidated[Doc]([]byte(`{"tags": null, "labels": null, "data": null, "point": null}`))
+	assert.EqualError(t, err, "json: validation failed: /point: expected array, got null")
+}